@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ByteMode selects how byte arrays and slices, including cgo char arrays, are
+// printed.
+type ByteMode int
+
+const (
+	// BytesDefault prints hexdumps with Dump and lists of decimal numbers
+	// with the custom formatter.
+	BytesDefault ByteMode = iota
+
+	// BytesHexdump prints hexdumps in hexdump -C fashion with Dump and
+	// compact hex strings with the custom formatter, which writes values on
+	// a single line.
+	BytesHexdump
+
+	// BytesString prints quoted strings when the bytes are valid UTF-8 and
+	// falls back to BytesHexdump otherwise.
+	BytesString
+
+	// BytesBase64 prints the standard base64 encoding of the bytes.
+	BytesBase64
+
+	// BytesHex prints compact hex strings such as 68656c6c6f.
+	BytesHex
+
+	// BytesAuto prints strings when the bytes are valid UTF-8 made of
+	// printable runes and whitespace, compact hex strings of at most
+	// autoHexMax bytes and hexdumps otherwise.
+	BytesAuto
+)
+
+// autoHexMax is the maximum number of bytes BytesAuto prints as a compact hex
+// string.
+const autoHexMax = 32
+
+// byteModeNames maps the values of the bytes directive of a field tag to the
+// ByteMode they select.
+var byteModeNames = map[string]ByteMode{
+	"hexdump": BytesHexdump,
+	"string":  BytesString,
+	"base64":  BytesBase64,
+	"hex":     BytesHex,
+	"auto":    BytesAuto,
+}
+
+// resolve returns the mode buf is printed in, which is BytesHexdump,
+// BytesString, BytesBase64 or BytesHex for every mode but BytesDefault.
+func (m ByteMode) resolve(buf []byte) ByteMode {
+	switch m {
+	case BytesString:
+		if !utf8.Valid(buf) {
+			return BytesHexdump
+		}
+	case BytesAuto:
+		switch {
+		case isText(buf):
+			return BytesString
+		case len(buf) <= autoHexMax:
+			return BytesHex
+		default:
+			return BytesHexdump
+		}
+	}
+	return m
+}
+
+// isText returns whether buf is valid UTF-8 made of printable runes and
+// whitespace.
+func isText(buf []byte) bool {
+	for len(buf) > 0 {
+		r, size := utf8.DecodeRune(buf)
+		if r == utf8.RuneError && size <= 1 || !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+		buf = buf[size:]
+	}
+	return true
+}
+
+// appendBytes appends buf encoded in the inline mode m, which is BytesString,
+// BytesBase64 or BytesHex, to dst.  Strings are quoted, so control characters
+// are escaped.
+func appendBytes(dst, buf []byte, m ByteMode) []byte {
+	switch m {
+	case BytesString:
+		return strconv.AppendQuote(dst, string(buf))
+	case BytesBase64:
+		n := len(dst)
+		dst = append(dst, make([]byte, base64.StdEncoding.EncodedLen(len(buf)))...)
+		base64.StdEncoding.Encode(dst[n:], buf)
+		return dst
+	default:
+		n := len(dst)
+		dst = append(dst, make([]byte, hex.EncodedLen(len(buf)))...)
+		hex.Encode(dst[n:], buf)
+		return dst
+	}
+}
@@ -0,0 +1,187 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"io"
+	"os"
+)
+
+// Theme specifies the colors of the token classes written by Dump and the
+// custom formatter.  Every color is a list of ANSI SGR parameters such as "36"
+// for cyan or "1;34" for bold blue.  Tokens of a class with an empty color are
+// written without color.
+type Theme struct {
+	// Type colors type names such as (int) or (*main.Foo).
+	Type string
+
+	// Field colors the names of struct fields.
+	Field string
+
+	// String colors strings.
+	String string
+
+	// Number colors integers, floating point and complex numbers.
+	Number string
+
+	// Bool colors booleans.
+	Bool string
+
+	// Nil colors <nil> and <invalid> markers.
+	Nil string
+
+	// Pointer colors pointer addresses.
+	Pointer string
+
+	// Length colors len and cap annotations.
+	Length string
+
+	// Circular colors the markers of circular references.
+	Circular string
+
+	// MaxDepth colors the markers of values beyond MaxDepth.
+	MaxDepth string
+
+	// Redacted colors the markers of redacted values.
+	Redacted string
+}
+
+// DefaultTheme is a theme for terminals with a dark or light background.
+var DefaultTheme = Theme{
+	Type:     "32",
+	Field:    "33",
+	String:   "36",
+	Number:   "35",
+	Bool:     "35",
+	Nil:      "1;31",
+	Pointer:  "34",
+	Length:   "2",
+	Circular: "1;33",
+	MaxDepth: "1;33",
+	Redacted: "1;31",
+}
+
+// tokenClass identifies the class of a token for coloring.
+type tokenClass int
+
+const (
+	tokenType tokenClass = iota
+	tokenField
+	tokenString
+	tokenNumber
+	tokenBool
+	tokenNil
+	tokenPointer
+	tokenLength
+	tokenCircular
+	tokenMaxDepth
+	tokenRedacted
+)
+
+// Some constants in the form of bytes to avoid string overhead when writing
+// ANSI escape sequences.
+var (
+	sgrStartBytes = []byte("\x1b[")
+	sgrEndBytes   = []byte("m")
+	sgrResetBytes = []byte("\x1b[0m")
+)
+
+// color returns the SGR parameters of the token class.  A nil theme doesn't
+// color any token.
+func (t *Theme) color(class tokenClass) string {
+	if t == nil {
+		return ""
+	}
+	switch class {
+	case tokenType:
+		return t.Type
+	case tokenField:
+		return t.Field
+	case tokenString:
+		return t.String
+	case tokenNumber:
+		return t.Number
+	case tokenBool:
+		return t.Bool
+	case tokenNil:
+		return t.Nil
+	case tokenPointer:
+		return t.Pointer
+	case tokenLength:
+		return t.Length
+	case tokenCircular:
+		return t.Circular
+	case tokenMaxDepth:
+		return t.MaxDepth
+	case tokenRedacted:
+		return t.Redacted
+	}
+	return ""
+}
+
+// start writes the escape sequence which starts coloring a token of the
+// passed class to w.
+func (t *Theme) start(w io.Writer, class tokenClass) {
+	if sgr := t.color(class); sgr != "" {
+		w.Write(sgrStartBytes)
+		io.WriteString(w, sgr)
+		w.Write(sgrEndBytes)
+	}
+}
+
+// end writes the escape sequence which stops coloring a token of the passed
+// class to w.
+func (t *Theme) end(w io.Writer, class tokenClass) {
+	if t.color(class) != "" {
+		w.Write(sgrResetBytes)
+	}
+}
+
+// writeToken writes b to w colored as a token of the passed class.
+func (t *Theme) writeToken(w io.Writer, class tokenClass, b []byte) {
+	t.start(w, class)
+	w.Write(b)
+	t.end(w, class)
+}
+
+// isTerminal returns whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// theme returns the theme used to color output written to w, or nil if the
+// output isn't colored.  The output is only colored when a theme is set, the
+// NO_COLOR environment variable is empty and either w is a terminal or
+// ForceColor is set.
+func (c *ConfigState) theme(w io.Writer) *Theme {
+	if c.Theme == nil || os.Getenv("NO_COLOR") != "" {
+		return nil
+	}
+	if !c.ForceColor && !isTerminal(w) {
+		return nil
+	}
+	return c.Theme
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spewerspew/spew"
+)
+
+// colorStruct is used to test coloring of every token class.
+type colorStruct struct {
+	S string
+	N int
+	B bool
+	P *int
+	C *colorStruct
+}
+
+// TestColor ensures the tokens written by Dump and the custom formatter are
+// colored according to the theme.
+func TestColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	theme := spew.Theme{
+		Type:     "T",
+		Field:    "F",
+		String:   "S",
+		Number:   "N",
+		Bool:     "B",
+		Nil:      "X",
+		Length:   "L",
+		Circular: "C",
+		MaxDepth: "M",
+	}
+	cs := spew.ConfigState{Indent: " ", DisablePointerAddresses: true, Theme: &theme, ForceColor: true}
+	v := &colorStruct{S: "a", N: 1, B: true}
+	v.C = v
+
+	c := func(sgr, s string) string {
+		return "\x1b[" + sgr + "m" + s + "\x1b[0m"
+	}
+	want := c("T", "(*spew_test.colorStruct)") + "({\n" +
+		" " + c("F", "S") + ": " + c("T", "(string)") + " " + c("L", "(len=1)") + " " + c("S", `"a"`) + ",\n" +
+		" " + c("F", "N") + ": " + c("T", "(int)") + " " + c("N", "1") + ",\n" +
+		" " + c("F", "B") + ": " + c("T", "(bool)") + " " + c("B", "true") + ",\n" +
+		" " + c("F", "P") + ": " + c("T", "(*int)") + "(" + c("X", "<nil>") + "),\n" +
+		" " + c("F", "C") + ": " + c("T", "(*spew_test.colorStruct)") + "(" + c("C", "<already shown>") + ")\n" +
+		"})\n"
+	if s := cs.Sdump(v); s != want {
+		t.Errorf("Sdump\n got: %q\nwant: %q", s, want)
+	}
+
+	want = c("T", "<*>") + "{" + c("S", "a") + " " + c("N", "1") + " " + c("B", "true") + " " +
+		c("X", "<nil>") + " " + c("T", "<*>") + c("C", "<shown>") + "}"
+	if s := cs.Sprintf("%v", v); s != want {
+		t.Errorf("Sprintf\n got: %q\nwant: %q", s, want)
+	}
+
+	cs.MaxDepth = 1
+	want = c("T", "([][]int)") + " " + c("L", "(len=1 cap=1)") + " {\n" +
+		" " + c("T", "([]int)") + " " + c("L", "(len=1 cap=1)") + " {\n" +
+		"  " + c("M", "<max depth reached>") + "\n" +
+		" }\n" +
+		"}\n"
+	if s := cs.Sdump([][]int{{1}}); s != want {
+		t.Errorf("Sdump max depth\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestColorDisabled ensures output is only colored when a theme is set and
+// either the writer is a terminal or ForceColor is set, unless NO_COLOR is
+// set.
+func TestColorDisabled(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	want := "(int) 1\n"
+
+	cs := spew.ConfigState{Theme: &spew.DefaultTheme}
+	buf := new(bytes.Buffer)
+	cs.Fdump(buf, 1)
+	if s := buf.String(); s != want {
+		t.Errorf("Fdump to non-terminal\n got: %q\nwant: %q", s, want)
+	}
+
+	cs = spew.ConfigState{ForceColor: true}
+	if s := cs.Sdump(1); s != want {
+		t.Errorf("Sdump without theme\n got: %q\nwant: %q", s, want)
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	cs = spew.ConfigState{Theme: &spew.DefaultTheme, ForceColor: true}
+	if s := cs.Sdump(1); s != want {
+		t.Errorf("Sdump with NO_COLOR\n got: %q\nwant: %q", s, want)
+	}
+	if s := cs.Sprint(1); s != "1" {
+		t.Errorf("Sprint with NO_COLOR\n got: %q\nwant: %q", s, "1")
+	}
+}
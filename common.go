@@ -19,12 +19,20 @@ package spew
 
 import (
 	"bytes"
+	"context"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"path"
 	"reflect"
+	"regexp"
+	"runtime/debug"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 // Some constants in the form of bytes to avoid string overhead.  This mirrors
@@ -49,10 +57,11 @@ var (
 	spaceBytes            = []byte(" ")
 	pointerChainBytes     = []byte("->")
 	nilAngleBytes         = []byte("<nil>")
-	maxNewlineBytes       = []byte("<max depth reached>\n")
+	maxBytes              = []byte("<max depth reached>")
 	maxShortBytes         = []byte("<max>")
 	circularBytes         = []byte("<already shown>")
 	circularShortBytes    = []byte("<shown>")
+	pointerIDBytes        = []byte("p")
 	invalidAngleBytes     = []byte("<invalid>")
 	openBracketBytes      = []byte("[")
 	closeBracketBytes     = []byte("]")
@@ -64,27 +73,132 @@ var (
 	closeMapBytes         = []byte("]")
 	lenEqualsBytes        = []byte("len=")
 	capEqualsBytes        = []byte("cap=")
+	elidedBytes           = []byte("...(")
+	moreParenBytes        = []byte(" more)")
+	truncatedBytes        = []byte("<truncated: ")
+	moreAngleBytes        = []byte(" more>")
+	canceledBytes         = []byte("<canceled: ")
+	timedOutBytes         = []byte("<method timed out>")
+	minusBytes            = []byte("-")
+	hexPrefixBytes        = []byte("0x")
+	redactedBytes         = []byte("<redacted")
+	spaceLenEqualsBytes   = []byte(" len=")
 )
 
 // hexDigits is used to map a decimal value to a hex digit.
 const hexDigits = "0123456789abcdef"
 
-// catchPanic handles any panics that might occur during the handleMethods
-// calls.
-func catchPanic(w io.Writer, v reflect.Value) {
+// methodStats counts the Error and String methods called by a dump and
+// records the panics of the ones which panicked along with the index arg of
+// the argument being printed and the access path path returns for the value,
+// if set.  A nil *methodStats counts nothing.
+type methodStats struct {
+	invoked  int
+	panicked int
+	timedOut int
+	panics   []MethodPanic
+	arg      int
+	path     func() string
+}
+
+// recordPanic counts the panic of the method of a value of type t described
+// by r.
+func (s *methodStats) recordPanic(t reflect.Type, r methodResult) {
+	s.panicked++
+	p := MethodPanic{Arg: s.arg, Type: t, Value: r.panicVal, Stack: r.stack}
+	if s.path != nil {
+		p.Path = s.path()
+	}
+	s.panics = append(s.panics, p)
+}
+
+// methodCounter is implemented by the printers which count the methods
+// printValue calls for them.
+type methodCounter interface {
+	methodStats() *methodStats
+}
+
+// methodResult is the outcome of a call of an Error or String method.
+type methodResult struct {
+	s        string
+	panicked bool
+	panicVal interface{}
+	stack    []byte
+	timedOut bool
+}
+
+// catchPanic records any panics that might occur during the handleMethods
+// calls in r along with the stack trace of the panic.
+func catchPanic(r *methodResult) {
 	if err := recover(); err != nil {
-		w.Write(panicBytes)
-		fmt.Fprintf(w, "%v", err)
-		w.Write(closeParenBytes)
+		r.panicked = true
+		r.panicVal = err
+		r.stack = debug.Stack()
+	}
+}
+
+// callMethod calls method and returns its result, recovering from panics.
+// The call c, if any, is in progress on the calling goroutine gid while
+// method runs.
+func callMethod(method func() string, c *methodCall, gid int64) (r methodResult) {
+	if c != nil {
+		defer c.enter(gid)()
+	}
+	defer catchPanic(&r)
+	r.s = method()
+	return r
+}
+
+// callMethodTimeout calls method like callMethod, but gives up waiting for it
+// after the timeout d unless d is 0.  The method is called on a goroutine of
+// its own then, which keeps running in the background when it times out and
+// inherits the calls in progress on the calling goroutine.
+func callMethodTimeout(method func() string, d time.Duration, c *methodCall) methodResult {
+	if d <= 0 {
+		if c == nil {
+			return callMethod(method, nil, 0)
+		}
+		return callMethod(method, c, c.gid)
+	}
+	done := make(chan methodResult, 1)
+	go func() {
+		var gid int64
+		if c != nil {
+			gid = goroutineID()
+		}
+		done <- callMethod(method, c, gid)
+	}()
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case r := <-done:
+		return r
+	case <-t.C:
+		return methodResult{timedOut: true}
 	}
 }
 
 // handleMethods attempts to call the Error and String methods on the underlying
 // type the passed reflect.Value represents and outputes the result to Writer w.
+// The calls are counted in stats.
 //
 // It handles panics in any called methods by catching and displaying the error
-// as the formatted value.
-func handleMethods(cs *ConfigState, w io.Writer, v reflect.Value) (handled bool) {
+// as the formatted value.  Methods which don't return within the MethodTimeout
+// option are displayed as a marker followed by the value itself.  Methods of
+// types denied by the MethodPolicy option and of nil pointers unless the
+// NilMethods option is NilMethodsCall aren't called, and neither are methods
+// already in progress for the same value on the calling goroutine with the
+// DetectMethodReentry option, so the value is printed as if it had no methods
+// instead.
+func handleMethods(cs *ConfigState, w io.Writer, v reflect.Value, stats *methodStats) (handled bool) {
+	vt, addressable := v.Type(), v.CanAddr()
+	if !cs.methodsAllowed(vt) {
+		return false
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() && cs.NilMethods != NilMethodsCall {
+		return false
+	}
+
 	// We need an interface to check if the type implements the error or
 	// Stringer interface.  However, the reflect package won't give us an
 	// interface on certain things like unexported struct fields in order
@@ -108,37 +222,105 @@ func handleMethods(cs *ConfigState, w io.Writer, v reflect.Value) (handled bool)
 	if !cs.DisablePointerMethods && !UnsafeDisabled && !v.CanAddr() {
 		v = unsafeReflectValue(v)
 	}
-	if v.CanAddr() {
+	value := v
+	if v.CanAddr() && v.Kind() != reflect.Ptr {
 		v = v.Addr()
 	}
 
 	// Is it an error or Stringer?
+	var method func() string
 	switch iface := v.Interface().(type) {
 	case error:
-		defer catchPanic(w, v)
-		if cs.ContinueOnMethod {
-			w.Write(openParenBytes)
-			io.WriteString(w, iface.Error())
-			w.Write(closeParenBytes)
-			w.Write(spaceBytes)
+		method = iface.Error
+	case fmt.Stringer:
+		method = iface.String
+	default:
+		return false
+	}
+
+	// Don't call the method again when it prints the same value with spew
+	// itself, since it would only recurse until the stack overflows.
+	var call *methodCall
+	if reentryGuarded(cs) {
+		var reentered bool
+		call, reentered = newMethodCall(cs, newMethodKey(value, addressable), v)
+		if reentered {
 			return false
 		}
-		io.WriteString(w, iface.Error())
-		return true
+	}
 
-	case fmt.Stringer:
-		defer catchPanic(w, v)
-		if cs.ContinueOnMethod {
-			w.Write(openParenBytes)
-			io.WriteString(w, iface.String())
-			w.Write(closeParenBytes)
-			w.Write(spaceBytes)
-			return false
+	r := callMethodTimeout(method, cs.MethodTimeout, call)
+	if stats != nil {
+		stats.invoked++
+	}
+	switch {
+	case r.timedOut:
+		if stats != nil {
+			stats.timedOut++
 		}
-		io.WriteString(w, iface.String())
-		return true
+		w.Write(timedOutBytes)
+		w.Write(spaceBytes)
+		return false
+
+	case r.panicked:
+		if stats != nil {
+			stats.recordPanic(vt, r)
+		}
+		w.Write(panicBytes)
+		fmt.Fprintf(w, "%v", r.panicVal)
+		w.Write(closeParenBytes)
+		return false
+
+	case cs.ContinueOnMethod:
+		w.Write(openParenBytes)
+		io.WriteString(w, r.s)
+		w.Write(closeParenBytes)
+		w.Write(spaceBytes)
+		return false
 	}
-	return false
+	io.WriteString(w, r.s)
+	return true
+}
+
+// errWriter passes writes through to w until one of them fails and counts
+// the bytes written.  Once a write failed, later writes are dropped and return
+// the error of the failed one.
+type errWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.w.Write(p)
+	e.n += int64(n)
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	e.err = err
+	return n, err
+}
+
+// WriteString writes s without copying it into a byte slice when the
+// underlying writer implements io.StringWriter.
+func (e *errWriter) WriteString(s string) (int, error) {
+	sw, ok := e.w.(io.StringWriter)
+	if !ok {
+		return e.Write([]byte(s))
+	}
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := sw.WriteString(s)
+	e.n += int64(n)
+	if err == nil && n < len(s) {
+		err = io.ErrShortWrite
+	}
+	e.err = err
+	return n, err
 }
 
 // printInt outputs a signed integer value to Writer w.
@@ -253,22 +435,40 @@ func printHexPtr(w io.Writer, p uintptr) {
 // cycleInfo stores circular pointer information.
 type cycleInfo struct {
 	pointers     map[uintptr]int
+	visited      []visitedPtr
 	pointerChain []uintptr
 	nilFound     bool
 	cycleFound   bool
 	indirects    int
 }
 
+// visitedPtr is a pointer in cycleInfo.pointers along with the depth it was
+// reached at.  They are kept in the order they were reached, which is also
+// the order of their depths, so the ones reached below a depth can be removed
+// without looking at the others.
+type visitedPtr struct {
+	addr  uintptr
+	depth int
+}
+
 func (ci *cycleInfo) Reset() {
-	for k := range ci.pointers {
-		delete(ci.pointers, k)
-	}
+	ci.resetPointers()
 	*ci = cycleInfo{
 		pointers:     ci.pointers,
+		visited:      ci.visited,
 		pointerChain: ci.pointerChain[:0],
 	}
 }
 
+// resetPointers forgets all of the pointers reached so far, such as before
+// printing the next top-level value.
+func (ci *cycleInfo) resetPointers() {
+	for k := range ci.pointers {
+		delete(ci.pointers, k)
+	}
+	ci.visited = ci.visited[:0]
+}
+
 var cycleInfoPool = sync.Pool{New: func() interface{} {
 	return &cycleInfo{pointers: make(map[uintptr]int)}
 }}
@@ -280,6 +480,19 @@ func cycleInfoGet() *cycleInfo {
 	return ci
 }
 
+// circular returns whether the pointer v, when printed at the passed depth,
+// refers to a value which is already being printed.
+func (ci *cycleInfo) circular(v reflect.Value, depth int) bool {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return false
+	}
+	pd, ok := ci.pointers[v.Pointer()]
+	return ok && pd < depth
+}
+
 // derefPtr dereferences a pointer and unpacks interfaces down
 // the chain while detecting circular references.
 func derefPtr(v reflect.Value, depth int, ci *cycleInfo) reflect.Value {
@@ -287,11 +500,12 @@ func derefPtr(v reflect.Value, depth int, ci *cycleInfo) reflect.Value {
 
 	// Remove pointers at or below the current depth from map used to detect
 	// circular refs.
-	for k, d := range ci.pointers {
-		if d >= depth {
-			delete(ci.pointers, k)
-		}
+	n := len(ci.visited)
+	for n > 0 && ci.visited[n-1].depth >= depth {
+		n--
+		delete(ci.pointers, ci.visited[n].addr)
 	}
+	ci.visited = ci.visited[:n]
 
 	// Figure out how many levels of indirection there are by dereferencing
 	// pointers and unpacking interfaces down the chain while detecting circular
@@ -314,6 +528,7 @@ func derefPtr(v reflect.Value, depth int, ci *cycleInfo) reflect.Value {
 			break
 		}
 		ci.pointers[addr] = depth
+		ci.visited = append(ci.visited, visitedPtr{addr, depth})
 
 		ve = ve.Elem()
 		if ve.Kind() == reflect.Interface {
@@ -327,6 +542,721 @@ func derefPtr(v reflect.Value, depth int, ci *cycleInfo) reflect.Value {
 	return ve
 }
 
+// ptrKey identifies a pointer by its address and the type of the value it
+// points to, after unpacking interfaces, so pointers to a struct and to its
+// first field are told apart.
+type ptrKey struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// pointerKey returns the ptrKey of the non-nil pointer v.
+func pointerKey(v reflect.Value) ptrKey {
+	ve := v.Elem()
+	if ve.Kind() == reflect.Interface && !ve.IsNil() {
+		ve = ve.Elem()
+	}
+	return ptrKey{v.Pointer(), ve.Type()}
+}
+
+// pointerCensus counts how often every pointer is reached while walking a
+// value.  It is used by the renderers which refer back to shared and circular
+// pointers instead of only detecting cycles.
+type pointerCensus struct {
+	counts map[ptrKey]int
+	order  []reflect.Value
+}
+
+// collect walks v and counts how often every pointer is reached.  Pointers
+// are only followed the first time they are reached, so circular data
+// structures are handled properly.  The pointers are also recorded in the
+// order they were first reached.  The values still to be walked are kept on
+// an explicit stack, so the depth of v is only limited by the heap.
+func (pc *pointerCensus) collect(v reflect.Value) {
+	stack := []reflect.Value{v}
+	for len(stack) > 0 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		// Values contained in v are pushed in reverse so they are walked
+		// in order.
+		n := len(stack)
+		switch v.Kind() {
+		case reflect.Ptr:
+			if v.IsNil() {
+				continue
+			}
+			key := pointerKey(v)
+			pc.counts[key]++
+			if pc.counts[key] > 1 {
+				continue
+			}
+			pc.order = append(pc.order, v)
+			stack = append(stack, v.Elem())
+
+		case reflect.Interface:
+			if !v.IsNil() {
+				stack = append(stack, v.Elem())
+			}
+
+		case reflect.Array, reflect.Slice:
+			// Elements of basic kinds can't hold any pointers.
+			if kind := v.Type().Elem().Kind(); kind <= reflect.Complex128 ||
+				kind == reflect.String {
+				continue
+			}
+			for i := 0; i < v.Len(); i++ {
+				stack = append(stack, v.Index(i))
+			}
+
+		case reflect.Map:
+			iter := v.MapRange()
+			for iter.Next() {
+				stack = append(stack, iter.Key(), iter.Value())
+			}
+
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				stack = append(stack, v.Field(i))
+			}
+		}
+		for i, j := n, len(stack)-1; i < j; i, j = i+1, j-1 {
+			stack[i], stack[j] = stack[j], stack[i]
+		}
+	}
+}
+
+// walkFrame is an entry of the explicit stack Dump and the custom formatter
+// keep for the pointers, arrays, slices, maps and structs they are writing,
+// so the depth of the values they print is only limited by the heap instead
+// of the goroutine stack.  The frame on top of the stack is stepped once the
+// value it started last is written, which continues with its next entry or
+// ends it.
+type walkFrame struct {
+	// kind is reflect.Ptr, reflect.Array for arrays and slices,
+	// reflect.Map or reflect.Struct.
+	kind reflect.Kind
+	v    reflect.Value
+	keys []reflect.Value
+
+	// next is the index of the next entry to write and printed the number
+	// of struct fields written so far.  entry is set while the entry
+	// before next is being written and value once the value of a map entry
+	// is being written after its key.
+	next    int
+	printed int
+	entry   bool
+	value   bool
+
+	// field is the fieldState to restore once a struct is written.
+	field fieldState
+}
+
+// walkStack is the explicit stack of a traversal.
+type walkStack []walkFrame
+
+// push pushes a frame for v of the passed kind.
+func (s *walkStack) push(kind reflect.Kind, v reflect.Value) *walkFrame {
+	*s = append(*s, walkFrame{kind: kind, v: v})
+	return &(*s)[len(*s)-1]
+}
+
+// top returns the frame on top of the stack.  The frame is only valid until
+// the next push.
+func (s walkStack) top() *walkFrame {
+	return &s[len(s)-1]
+}
+
+// pop removes the frame on top of the stack without retaining its values.
+func (s *walkStack) pop() {
+	n := len(*s) - 1
+	(*s)[n] = walkFrame{}
+	*s = (*s)[:n]
+}
+
+// path returns the access path below the parent path of the entries being
+// written by the frames of the stack.
+func (s walkStack) path(cs *ConfigState, parent string) string {
+	for i := range s {
+		fr := &s[i]
+		if fr.next == 0 {
+			continue
+		}
+		switch fr.kind {
+		case reflect.Array:
+			parent = indexPath(parent, fr.next-1)
+		case reflect.Map:
+			parent = keyPath(cs, parent, fr.keys[fr.next-1])
+		case reflect.Struct:
+			parent = fieldPath(parent, fr.v.Type().Field(fr.next-1).Name)
+		}
+	}
+	return parent
+}
+
+// reset empties the stack, such as after a panic left frames behind.
+func (s *walkStack) reset() {
+	for len(*s) > 0 {
+		s.pop()
+	}
+}
+
+// pointerLabels assigns numbered labels to the pointers which are reached more
+// than once while printing values, so their first occurrence can be written
+// as &N and later ones as *N.
+type pointerLabels struct {
+	census   pointerCensus
+	labels   map[ptrKey]int
+	assigned []ptrKey
+}
+
+// reset prepares pl to label the pointers shared by the values a.
+func (pl *pointerLabels) reset(a ...interface{}) {
+	pl.census = pointerCensus{counts: make(map[ptrKey]int)}
+	pl.labels = make(map[ptrKey]int)
+	pl.assigned = pl.assigned[:0]
+	for _, arg := range a {
+		pl.census.collect(reflect.ValueOf(arg))
+	}
+}
+
+// enabled returns whether pointers are labeled.
+func (pl *pointerLabels) enabled() bool {
+	return pl != nil && pl.labels != nil
+}
+
+// label returns the label of the pointer key and whether it was assigned
+// before.  Labels are only assigned to pointers which are reached more than
+// once, the label of other pointers is 0.
+func (pl *pointerLabels) label(key ptrKey) (n int, seen bool) {
+	if n, ok := pl.labels[key]; ok {
+		return n, true
+	}
+	if pl.census.counts[key] < 2 {
+		return 0, false
+	}
+	pl.assigned = append(pl.assigned, key)
+	n = len(pl.assigned)
+	pl.labels[key] = n
+	return n, false
+}
+
+// rollback removes the labels assigned after the first mark ones, so values
+// written speculatively can be written again.
+func (pl *pointerLabels) rollback(mark int) {
+	for _, key := range pl.assigned[mark:] {
+		delete(pl.labels, key)
+	}
+	pl.assigned = pl.assigned[:mark]
+}
+
+// printLabel writes the label n to Writer w preceded by the passed sigil, '&'
+// for the first occurrence of a pointer and '*' for later ones.
+func printLabel(w io.Writer, sigil byte, n int, t *Theme) {
+	class := tokenPointer
+	if sigil == '*' {
+		class = tokenCircular
+	}
+	t.start(w, class)
+	w.Write([]byte{sigil})
+	printInt(w, int64(n), 10)
+	t.end(w, class)
+}
+
+// pointerIDs assigns symbolic IDs to pointer addresses in the order they are
+// printed, so output shows which pointers are equal without depending on the
+// actual addresses.
+type pointerIDs struct {
+	ids   map[uintptr]int
+	order []uintptr
+}
+
+// reset prepares ids to assign new IDs.
+func (ids *pointerIDs) reset() {
+	ids.ids = make(map[uintptr]int)
+	ids.order = ids.order[:0]
+}
+
+// enabled returns whether pointer addresses are replaced by IDs.
+func (ids *pointerIDs) enabled() bool {
+	return ids != nil && ids.ids != nil
+}
+
+// rollback removes the IDs assigned after the first mark ones, so values
+// written speculatively can be written again.
+func (ids *pointerIDs) rollback(mark int) {
+	for _, addr := range ids.order[mark:] {
+		delete(ids.ids, addr)
+	}
+	ids.order = ids.order[:mark]
+}
+
+// print writes the ID of addr, such as p1, to Writer w when IDs are enabled
+// and the address itself otherwise.
+func (ids *pointerIDs) print(w io.Writer, addr uintptr) {
+	if !ids.enabled() {
+		printHexPtr(w, addr)
+		return
+	}
+	n, ok := ids.ids[addr]
+	if !ok {
+		ids.order = append(ids.order, addr)
+		n = len(ids.order)
+		ids.ids[addr] = n
+	}
+	w.Write(pointerIDBytes)
+	printInt(w, int64(n), 10)
+}
+
+// rootPath is the access path of the top-level value.
+const rootPath = "."
+
+// fieldPath returns the access path of the struct field name below the
+// parent path.  An empty parent or one ending in a dot, such as the root path
+// ".", is joined without an additional separator.
+func fieldPath(parent, name string) string {
+	if parent == "" || parent[len(parent)-1] == '.' {
+		return parent + name
+	}
+	return parent + "." + name
+}
+
+// indexPath returns the access path of the i'th array or slice element below
+// the parent path.
+func indexPath(parent string, i int) string {
+	return parent + "[" + strconv.Itoa(i) + "]"
+}
+
+// keyPath returns the access path of the map entry for key below the parent
+// path.  String keys are quoted and other keys are formatted with the %v
+// Formatter of cs.
+func keyPath(cs *ConfigState, parent string, key reflect.Value) string {
+	if key.Kind() == reflect.Interface && !key.IsNil() {
+		key = key.Elem()
+	}
+	switch key.Kind() {
+	case reflect.String:
+		return parent + "[" + strconv.Quote(key.String()) + "]"
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		return parent + "[" + strconv.FormatInt(key.Int(), 10) + "]"
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		return parent + "[" + strconv.FormatUint(key.Uint(), 10) + "]"
+	}
+	if !key.CanInterface() {
+		key = unsafeReflectValue(key)
+	}
+	if !key.CanInterface() {
+		return parent + "[" + key.String() + "]"
+	}
+	// The formatter is created without a theme, so paths are never colored.
+	return parent + "[" + fmt.Sprint(&formatState{value: key.Interface(), cs: cs}) + "]"
+}
+
+// fieldOptions holds the directives of a `spew:"..."` struct field tag.
+// Directives are separated by commas and the directive "-" skips the field:
+//
+//	redact     replace the value with a marker showing its type and length
+//	hex        print integers and strings in hex
+//	omitempty  skip the field when it holds the zero value
+//	maxlen=N   print at most N bytes of strings and N elements of arrays,
+//	           slices and maps
+//	depth=N    descend at most N levels into the value
+//	bytes=M    print byte arrays and slices in mode M, which is one of
+//	           hexdump, string, base64, hex and auto
+type fieldOptions struct {
+	skip      bool
+	redact    bool
+	hex       bool
+	omitEmpty bool
+	maxLen    int
+	depth     int
+	hasDepth  bool
+	bytes     ByteMode
+}
+
+// parseFieldTag parses the spew struct field tag.  Unknown directives are
+// ignored.
+func parseFieldTag(tag reflect.StructTag) (opts fieldOptions) {
+	value, ok := tag.Lookup("spew")
+	if !ok {
+		return opts
+	}
+	for _, directive := range strings.Split(value, ",") {
+		name, arg := directive, ""
+		if i := strings.IndexByte(directive, '='); i >= 0 {
+			name, arg = directive[:i], directive[i+1:]
+		}
+		switch strings.TrimSpace(name) {
+		case "-":
+			opts.skip = true
+		case "redact":
+			opts.redact = true
+		case "hex":
+			opts.hex = true
+		case "omitempty":
+			opts.omitEmpty = true
+		case "maxlen":
+			if n, err := strconv.Atoi(strings.TrimSpace(arg)); err == nil && n >= 0 {
+				opts.maxLen = n
+			}
+		case "depth":
+			if n, err := strconv.Atoi(strings.TrimSpace(arg)); err == nil && n >= 0 {
+				opts.depth = n
+				opts.hasDepth = true
+			}
+		case "bytes":
+			opts.bytes = byteModeNames[strings.TrimSpace(arg)]
+		}
+	}
+	return opts
+}
+
+// fieldOptionsCache caches the parsed field tags of struct types.
+var fieldOptionsCache sync.Map // map[reflect.Type][]fieldOptions
+
+// fieldOptionsOf returns the parsed tags of the fields of the struct type t.
+func fieldOptionsOf(t reflect.Type) []fieldOptions {
+	if cv, ok := fieldOptionsCache.Load(t); ok {
+		return cv.([]fieldOptions)
+	}
+	opts := make([]fieldOptions, t.NumField())
+	for i := range opts {
+		opts[i] = parseFieldTag(t.Field(i).Tag)
+	}
+	fieldOptionsCache.Store(t, opts)
+	return opts
+}
+
+// fieldsLeft returns the number of fields of the struct v from index i on
+// which aren't skipped according to their options opts.
+func fieldsLeft(v reflect.Value, opts []fieldOptions, i int) int {
+	n := 0
+	for ; i < len(opts); i++ {
+		if !opts[i].skip && !(opts[i].omitEmpty && v.Field(i).IsZero()) {
+			n++
+		}
+	}
+	return n
+}
+
+// fieldState holds the directives of the struct field tag which apply to the
+// value being printed.  They apply to the value of the field as well as the
+// elements of the arrays, slices and maps it holds, but not to the fields of
+// nested structs which have their own tags.
+type fieldState struct {
+	hex        bool
+	maxLen     int
+	depthLimit int
+	bytes      ByteMode
+}
+
+// enter returns the state for printing the value of a field with the passed
+// options at the passed depth.  Depth limits of enclosing fields still apply.
+func (fs fieldState) enter(o fieldOptions, depth int) fieldState {
+	n := fieldState{
+		hex:        o.hex,
+		maxLen:     o.maxLen,
+		depthLimit: fs.depthLimit,
+		bytes:      o.bytes,
+	}
+	if o.hasDepth {
+		if limit := depth + o.depth; n.depthLimit == 0 || limit < n.depthLimit {
+			n.depthLimit = limit
+		}
+	}
+	return n
+}
+
+// maxDepthReached returns whether values at the passed depth are beyond the
+// configured MaxDepth or the depth limit of the field being printed.
+func (fs fieldState) maxDepthReached(cs *ConfigState, depth int) bool {
+	return (cs.MaxDepth != 0 && depth > cs.MaxDepth) ||
+		(fs.depthLimit != 0 && depth > fs.depthLimit)
+}
+
+// byteMode returns the ByteMode of byte arrays and slices according to the
+// bytes directive or, without one, the ByteMode option.
+func (fs fieldState) byteMode(cs *ConfigState) ByteMode {
+	if fs.bytes != BytesDefault {
+		return fs.bytes
+	}
+	return cs.ByteMode
+}
+
+// span returns the number of leading and trailing entries of a value with n
+// entries that are printed according to the maxlen directive or, without one,
+// the MaxElements and TailElements options.  The entries in between are
+// elided.
+func (fs fieldState) span(cs *ConfigState, n int) (head, tail int) {
+	switch {
+	case fs.maxLen != 0:
+		if n > fs.maxLen {
+			return fs.maxLen, 0
+		}
+	case cs.MaxElements != 0:
+		if n > cs.MaxElements+cs.TailElements {
+			return cs.MaxElements, cs.TailElements
+		}
+	}
+	return n, 0
+}
+
+// truncateString returns the prefix of s printed according to the maxlen
+// directive or, without one, the MaxStringLen option.  The prefix doesn't
+// split UTF-8 encoded runes.
+func (fs fieldState) truncateString(cs *ConfigState, s string) string {
+	n := fs.maxLen
+	if n == 0 {
+		n = cs.MaxStringLen
+	}
+	if n == 0 {
+		return s
+	}
+	return runePrefix(s, n)
+}
+
+// runePrefix returns the longest prefix of s of at most n bytes which doesn't
+// split UTF-8 encoded runes.
+func runePrefix(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// truncateBytes returns the prefix of the byte array or slice buf printed in
+// the inline mode m according to the maxlen directive or, without one, the
+// MaxStringLen option for strings and the MaxElements option otherwise.
+func (fs fieldState) truncateBytes(cs *ConfigState, buf []byte, m ByteMode) []byte {
+	if m == BytesString {
+		return buf[:len(fs.truncateString(cs, string(buf)))]
+	}
+	head, _ := fs.span(cs, len(buf))
+	return buf[:head]
+}
+
+// printElided writes the marker of n elided bytes or entries to Writer w.
+func printElided(w io.Writer, n int) {
+	w.Write(elidedBytes)
+	printInt(w, int64(n), 10)
+	w.Write(moreParenBytes)
+}
+
+// printTruncated writes the marker of n entries skipped after the output
+// budget was used up to Writer w.
+func printTruncated(w io.Writer, n int, theme *Theme) {
+	theme.start(w, tokenMaxDepth)
+	w.Write(truncatedBytes)
+	printInt(w, int64(n), 10)
+	w.Write(moreAngleBytes)
+	theme.end(w, tokenMaxDepth)
+}
+
+// printCanceled writes the marker of a dump or format operation stopped by
+// the error err of its context to Writer w.
+func printCanceled(w io.Writer, err error, theme *Theme) {
+	theme.start(w, tokenMaxDepth)
+	w.Write(canceledBytes)
+	io.WriteString(w, err.Error())
+	w.Write(closeAngleBytes)
+	theme.end(w, tokenMaxDepth)
+}
+
+// ctxCheckInterval is the number of budget checks between the checks of the
+// context of a dump or format operation.
+const ctxCheckInterval = 64
+
+// outputBudget limits the output of a dump or format operation to the
+// MaxNodes values and MaxBytes bytes of a ConfigState and to the lifetime of
+// the context ctx, if any.  Once either is used up, the entries left of the
+// containers being written are skipped.
+type outputBudget struct {
+	maxNodes int
+	maxBytes int64
+	nodes    int
+	exceeded bool
+	ctx      context.Context
+	ctxErr   error
+	checks   int
+}
+
+// reset starts a new budget according to the options of cs for an operation
+// which stops once ctx is done.  A nil ctx is never done.
+func (b *outputBudget) reset(cs *ConfigState, ctx context.Context) {
+	*b = outputBudget{maxNodes: cs.MaxNodes, maxBytes: int64(cs.MaxBytes), ctx: ctx}
+}
+
+// enabled returns whether the number of bytes written is limited at all.
+func (b *outputBudget) enabled() bool {
+	return b.maxNodes > 0 || b.maxBytes > 0
+}
+
+// spent returns whether the budget is used up after n bytes were written.
+// The context is checked on the first call and every ctxCheckInterval calls
+// after it.  The budget stays used up once it is.
+func (b *outputBudget) spent(n int64) bool {
+	if b.exceeded {
+		return true
+	}
+	b.exceeded = b.maxNodes > 0 && b.nodes >= b.maxNodes ||
+		b.maxBytes > 0 && n >= b.maxBytes
+	if !b.exceeded && b.ctx != nil {
+		if b.checks%ctxCheckInterval == 0 {
+			b.ctxErr = b.ctx.Err()
+			b.exceeded = b.ctxErr != nil
+		}
+		b.checks++
+	}
+	return b.exceeded
+}
+
+// sepLen returns the length of the separator sep when it's pending, that is,
+// still to be written before the next entry, so the output budget is checked
+// as if it were written already.
+func sepLen(pending bool, sep []byte) int64 {
+	if !pending {
+		return 0
+	}
+	return int64(len(sep))
+}
+
+// minLeafBytes is the number of bytes of output leaf values are allowed even
+// when the budget is almost used up, so short strings are never cut.
+const minLeafBytes = 64
+
+// limit returns the number of units of a leaf value, such as the bytes of a
+// string, which fit into the budget after n bytes were written when every
+// unit takes at least size bytes of output.  It returns -1 without a MaxBytes
+// limit.
+func (b *outputBudget) limit(n int64, size int) int {
+	if b.maxBytes <= 0 {
+		return -1
+	}
+	left := b.maxBytes - n
+	if left < minLeafBytes {
+		left = minLeafBytes
+	}
+	return int(left / int64(size))
+}
+
+// RedactPolicy specifies the struct fields and string map keys whose values
+// are replaced by a marker showing only their type and length.
+type RedactPolicy struct {
+	// Fields specifies patterns of struct field names and string map keys,
+	// such as "*password*" or "Authorization".  The patterns use the syntax
+	// of path.Match and are matched case-insensitively.  Unexported fields
+	// are matched as well.  DefaultRedactFields holds patterns of commonly
+	// used names of secrets.
+	Fields []string
+
+	// Pattern specifies a regular expression which redacts the values of the
+	// struct fields and string map keys it matches the same way as Fields.
+	Pattern *regexp.Regexp
+}
+
+// DefaultRedactFields holds the patterns of field names and map keys which
+// commonly hold secrets.  It is meant to be assigned to or extended into
+// RedactPolicy.Fields.
+var DefaultRedactFields = []string{
+	"*password*",
+	"*passwd*",
+	"*token*",
+	"*secret*",
+	"*apikey*",
+	"*api_key*",
+	"authorization",
+	"cookie",
+	"set-cookie",
+}
+
+// redactsName returns whether the values of struct fields and map entries
+// with the passed name or string key are redacted according to the
+// RedactPolicy option.
+func (c *ConfigState) redactsName(name string) bool {
+	p := c.RedactPolicy
+	if p == nil {
+		return false
+	}
+	if p.Pattern != nil && p.Pattern.MatchString(name) {
+		return true
+	}
+	if len(p.Fields) == 0 {
+		return false
+	}
+	name = strings.ToLower(name)
+	for _, pattern := range p.Fields {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// redactsField returns whether the value of the struct field with the passed
+// options and name is redacted.
+func (c *ConfigState) redactsField(o fieldOptions, name string) bool {
+	return o.redact || c.redactsName(name)
+}
+
+// redactsKey returns whether the value of the map entry with the passed key
+// is redacted.  Only string keys are matched.
+func (c *ConfigState) redactsKey(key reflect.Value) bool {
+	if key.Kind() == reflect.Interface && !key.IsNil() {
+		key = key.Elem()
+	}
+	return key.Kind() == reflect.String && c.redactsName(key.String())
+}
+
+// printRedacted writes the marker of the redacted value v to Writer w.  The
+// marker only shows the length of values the built-in len function works
+// with.
+func printRedacted(w io.Writer, v reflect.Value, t *Theme) {
+	t.start(w, tokenRedacted)
+	w.Write(redactedBytes)
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.String, reflect.Chan:
+		w.Write(spaceLenEqualsBytes)
+		printInt(w, int64(v.Len()), 10)
+	}
+	w.Write(closeAngleBytes)
+	t.end(w, tokenRedacted)
+}
+
+// printHexValue writes integers and strings in hex to Writer w and returns
+// whether v was written.
+func printHexValue(w io.Writer, v reflect.Value, kind reflect.Kind, t *Theme) bool {
+	switch kind {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		t.start(w, tokenNumber)
+		i := v.Int()
+		if i < 0 {
+			w.Write(minusBytes)
+			i = -i
+		}
+		w.Write(hexPrefixBytes)
+		printUint(w, uint64(i), 16)
+		t.end(w, tokenNumber)
+
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		t.start(w, tokenNumber)
+		w.Write(hexPrefixBytes)
+		printUint(w, v.Uint(), 16)
+		t.end(w, tokenNumber)
+
+	case reflect.String:
+		t.start(w, tokenString)
+		io.WriteString(w, hex.EncodeToString([]byte(v.String())))
+		t.end(w, tokenString)
+
+	default:
+		return false
+	}
+	return true
+}
+
 type printer interface {
 	printArray(v reflect.Value)
 	printString(v reflect.Value)
@@ -335,12 +1265,16 @@ type printer interface {
 	defaultFormat() string
 }
 
-func printValue(w io.Writer, p printer, v reflect.Value, kind reflect.Kind, cs *ConfigState) {
+func printValue(w io.Writer, p printer, v reflect.Value, kind reflect.Kind, cs *ConfigState, t *Theme) {
 	// Call Stringer/error interfaces if they exist and the handle methods
 	// flag is enabled.
 	if !cs.DisableMethods {
 		if kind != reflect.Invalid && kind != reflect.Interface {
-			if handled := handleMethods(cs, w, v); handled {
+			var stats *methodStats
+			if mc, ok := p.(methodCounter); ok {
+				stats = mc.methodStats()
+			}
+			if handled := handleMethods(cs, w, v, stats); handled {
 				return
 			}
 		}
@@ -352,33 +1286,47 @@ func printValue(w io.Writer, p printer, v reflect.Value, kind reflect.Kind, cs *
 		// been handled before.
 
 	case reflect.Bool:
+		t.start(w, tokenBool)
 		if v.Bool() {
 			w.Write(trueBytes)
 		} else {
 			w.Write(falseBytes)
 		}
+		t.end(w, tokenBool)
 
 	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		t.start(w, tokenNumber)
 		printInt(w, v.Int(), 10)
+		t.end(w, tokenNumber)
 
 	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		t.start(w, tokenNumber)
 		printUint(w, v.Uint(), 10)
+		t.end(w, tokenNumber)
 
 	case reflect.Float32:
+		t.start(w, tokenNumber)
 		printFloat(w, v.Float(), 32)
+		t.end(w, tokenNumber)
 
 	case reflect.Float64:
+		t.start(w, tokenNumber)
 		printFloat(w, v.Float(), 64)
+		t.end(w, tokenNumber)
 
 	case reflect.Complex64:
+		t.start(w, tokenNumber)
 		printComplex(w, v.Complex(), 32)
+		t.end(w, tokenNumber)
 
 	case reflect.Complex128:
+		t.start(w, tokenNumber)
 		printComplex(w, v.Complex(), 64)
+		t.end(w, tokenNumber)
 
 	case reflect.Slice:
 		if v.IsNil() {
-			w.Write(nilAngleBytes)
+			t.writeToken(w, tokenNil, nilAngleBytes)
 			break
 		}
 		fallthrough
@@ -393,7 +1341,7 @@ func printValue(w io.Writer, p printer, v reflect.Value, kind reflect.Kind, cs *
 		// The only time we should get here is for nil interfaces due to
 		// unpackValue calls.
 		if v.IsNil() {
-			w.Write(nilAngleBytes)
+			t.writeToken(w, tokenNil, nilAngleBytes)
 		}
 
 	case reflect.Ptr:
@@ -403,7 +1351,7 @@ func printValue(w io.Writer, p printer, v reflect.Value, kind reflect.Kind, cs *
 	case reflect.Map:
 		// nil maps should be indicated as different than empty maps
 		if v.IsNil() {
-			w.Write(nilAngleBytes)
+			t.writeToken(w, tokenNil, nilAngleBytes)
 			break
 		}
 		p.printMap(v)
@@ -412,10 +1360,14 @@ func printValue(w io.Writer, p printer, v reflect.Value, kind reflect.Kind, cs *
 		p.printStruct(v)
 
 	case reflect.Uintptr:
+		t.start(w, tokenPointer)
 		printHexPtr(w, uintptr(v.Uint()))
+		t.end(w, tokenPointer)
 
 	case reflect.UnsafePointer, reflect.Chan, reflect.Func:
+		t.start(w, tokenPointer)
 		printHexPtr(w, v.Pointer())
+		t.end(w, tokenPointer)
 
 	// There were not any other types at the time this code was written, but
 	// fall back to letting the default fmt package handle it if any get added.
@@ -449,7 +1401,7 @@ func newValuesSorter(values []reflect.Value, cs *ConfigState) sort.Interface {
 		vs.strings = make([]string, len(values))
 		for i := range vs.values {
 			var b bytes.Buffer
-			if !handleMethods(cs, &b, vs.values[i]) {
+			if !handleMethods(cs, &b, vs.values[i], nil) {
 				vs.strings = nil
 				break
 			}
@@ -459,7 +1411,7 @@ func newValuesSorter(values []reflect.Value, cs *ConfigState) sort.Interface {
 	if vs.strings == nil && cs.SpewKeys {
 		vs.strings = make([]string, len(values))
 		for i := range vs.values {
-			vs.strings[i] = Sprintf("%#v", vs.values[i].Interface())
+			vs.strings[i] = fmt.Sprintf("%#v", &formatState{value: vs.values[i].Interface(), cs: &Config})
 		}
 	}
 	return vs
@@ -91,6 +91,49 @@ func (e customError) Error() string {
 	return fmt.Sprintf("error: %d", int(e))
 }
 
+// tagged is used to test the directives of spew struct field tags.
+type tagged struct {
+	Skipped  int     `spew:"-"`
+	Password string  `spew:"redact"`
+	Flags    uint16  `spew:"hex"`
+	Empty    []int   `spew:"omitempty"`
+	Long     string  `spew:"maxlen=3"`
+	Many     []int   `spew:"maxlen=2"`
+	Deep     [][]int `spew:"depth=1"`
+	Raw      []byte  `spew:"bytes=string"`
+	Plain    int
+}
+
+// omitted is used to test that the renderers leave out the fields skipped by
+// their tags.
+type omitted struct {
+	A    int
+	Skip int   `spew:"-"`
+	Opt  []int `spew:"omitempty"`
+}
+
+// node is used to test shared and circular pointers in the renderers.
+type node struct {
+	Name string
+	Next *node
+}
+
+// nodeCycle returns the first of two nodes, named a and b, which point to each
+// other.
+func nodeCycle() *node {
+	a := &node{Name: "a"}
+	a.Next = &node{Name: "b", Next: a}
+	return a
+}
+
+// renderTest is used to describe a test to be performed against one of the
+// renderers, such as Fjson or Fyaml, with the config cs.
+type renderTest struct {
+	cs   *spew.ConfigState
+	in   interface{}
+	want string
+}
+
 // stringizeWants converts a slice of wanted test output into a format suitable
 // for a test error message.
 func stringizeWants(wants []string) string {
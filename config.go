@@ -18,9 +18,11 @@
 package spew
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"time"
 )
 
 // ConfigState houses the configuration options used by spew to format and
@@ -49,6 +51,77 @@ type ConfigState struct {
 	// nested data structures.
 	MaxDepth int
 
+	// MaxElements specifies the maximum number of elements of arrays and
+	// slices, entries of maps and bytes of hexdumps to print.  The rest is
+	// replaced by a marker with the number of omitted entries, while the
+	// len= and cap= annotations still show the complete sizes.  The default,
+	// 0, means there is no limit.  The maxlen directive of a struct field tag
+	// takes precedence.
+	MaxElements int
+
+	// TailElements specifies the number of trailing elements, entries or
+	// bytes which are printed after the elision marker in addition to the
+	// leading MaxElements ones.  It has no effect unless MaxElements is set.
+	TailElements int
+
+	// MaxStringLen specifies the maximum number of bytes of strings to print,
+	// which are followed by a marker with the number of omitted bytes.  Runes
+	// are never split.  The default, 0, means there is no limit.
+	MaxStringLen int
+
+	// MaxBytes specifies the maximum number of bytes written by each call of
+	// Dump or by each value of the custom formatter.  Once it's reached, the
+	// entries left of the arrays, slices, maps and structs being written are
+	// replaced by a marker with their number and the containers are closed,
+	// so the output stays balanced.  Long strings and byte slices are cut
+	// short to fit.  The limit is checked between values, so the output can
+	// exceed it by the value being written and the closing braces.  The
+	// default, 0, means there is no limit.
+	MaxBytes int
+
+	// MaxNodes specifies the maximum number of values written by each call of
+	// Dump or by each value of the custom formatter, after which the rest is
+	// skipped the same as for MaxBytes.  The default, 0, means there is no
+	// limit.
+	MaxNodes int
+
+	// SymbolicPointers specifies whether the pointer addresses written by
+	// Dump and the %+v verb of the custom formatter are replaced by IDs such
+	// as p1 and p2, which are assigned to distinct addresses in the order
+	// they are written.  Output stays the same across runs while equal
+	// pointers are still recognizable.  DisablePointerAddresses takes
+	// precedence.
+	SymbolicPointers bool
+
+	// ReferenceLabels specifies whether pointers which are reached more than
+	// once, such as pointers shared by the nodes of a graph and circular
+	// pointers, are labeled across all values of a Dump call or a formatted
+	// value.  The first occurrence of such a pointer is written as &N
+	// followed by its value and later ones as *N instead of repeating the
+	// value or writing a circular reference marker.
+	ReferenceLabels bool
+
+	// LineWidth specifies the target width of the lines written by Dump.
+	// Arrays, slices, maps and structs which fit on the rest of their line
+	// are written inline in the style of the custom formatter, with field
+	// names, and only the ones which don't are expanded over multiple lines.
+	// LineWidthTerminal uses the width of the terminal the output is written
+	// to, or the COLUMNS environment variable, or 80 columns.  The default,
+	// 0, expands every value.
+	LineWidth int
+
+	// HexDump controls the layout of the hexdumps of byte arrays and slices,
+	// such as the number of bytes per line and the right-hand column.  The
+	// zero value matches the output of hexdump -C.
+	HexDump HexDumpOptions
+
+	// ByteMode specifies how byte arrays and slices are printed, such as
+	// hexdumps, strings, base64 or compact hex strings.  The default prints
+	// hexdumps with Dump and lists of decimal numbers with the custom
+	// formatter.  The bytes directive of a struct field tag takes
+	// precedence.
+	ByteMode ByteMode
+
 	// DisableMethods specifies whether or not error and Stringer interfaces are
 	// invoked for types that implement them.
 	DisableMethods bool
@@ -86,6 +159,41 @@ type ConfigState struct {
 	// via the DisableMethods or DisablePointerMethods options.
 	ContinueOnMethod bool
 
+	// MethodTimeout specifies the maximum time to wait for each call of an
+	// error or Stringer interface, such as a String method which blocks on a
+	// mutex the dumping goroutine holds.  Calls which don't return in time
+	// are displayed as <method timed out> followed by the value as if the
+	// method didn't exist.  The methods are called on a goroutine of their
+	// own when it's set.
+	//
+	// NOTE: Methods which time out can't be stopped.  They keep running in
+	// the background, along with their goroutine and any locks they take,
+	// and may access their receiver concurrently with the code which called
+	// spew.  The default, 0, means methods are called directly and waited for
+	// indefinitely.
+	MethodTimeout time.Duration
+
+	// MethodPolicy specifies the types whose error and Stringer interfaces
+	// are invoked, such as to avoid the methods which query a database.
+	// Values whose methods aren't invoked are displayed as if the methods
+	// didn't exist.  The default, nil, invokes the methods of all types.
+	MethodPolicy *MethodPolicy
+
+	// NilMethods specifies how the error and Stringer interfaces of nil
+	// pointers are handled.  The default, NilMethodsSkip, doesn't invoke
+	// them.
+	NilMethods NilMethodPolicy
+
+	// DetectMethodReentry specifies whether or not error and Stringer
+	// interfaces already in progress for a value on the calling goroutine,
+	// such as a String method which prints its own value with spew, are
+	// invoked again for it.  When it's set, the nested calls display the
+	// value as if the methods didn't exist instead of recursing until the
+	// stack overflows.  The nested calls are checked regardless of their own
+	// config.  This costs a stack trace of the calling goroutine for each
+	// method invoked, so it's disabled by default.
+	DetectMethodReentry bool
+
 	// SortKeys specifies map keys should be sorted before being printed. Use
 	// this to have a more deterministic, diffable output.  Note that only
 	// native types (bool, int, uint, floats, uintptr and string) and types
@@ -98,6 +206,37 @@ type ConfigState struct {
 	// be spewed to strings and sorted by those strings.  This is only
 	// considered if SortKeys is true.
 	SpewKeys bool
+
+	// GoSyntaxPackage specifies the import path of the package the output of
+	// GoSyntax is compiled in.  Types of that package are written without
+	// a package qualifier and their unexported fields are included.  The
+	// default, empty, treats every package as foreign, so unexported fields
+	// are only reported as comments.
+	GoSyntaxPackage string
+
+	// YAMLTypeComments specifies whether Fyaml writes the type of every value
+	// as a trailing comment.
+	YAMLTypeComments bool
+
+	// RedactPolicy specifies the struct fields and string map keys whose
+	// values are replaced by a marker showing only their type and length, by
+	// name patterns or a regular expression.  The default, nil, only redacts
+	// the fields tagged with redact.
+	RedactPolicy *RedactPolicy
+
+	// Theme specifies the colors used to highlight the output of Dump and
+	// the custom formatter.  The default, nil, disables colors.  Output is
+	// only colored when it's written to a terminal and the NO_COLOR
+	// environment variable is empty.  Set this to &DefaultTheme or to a
+	// custom Theme to enable colors.
+	Theme *Theme
+
+	// ForceColor specifies whether output is colored according to Theme even
+	// if it isn't written to a terminal, such as the output of Sdump or
+	// Sprintf.  The NO_COLOR environment variable still disables colors.
+	ForceColor bool
+
+	formatters *formatterRegistry
 }
 
 // Config is the active configuration of the top-level functions.
@@ -113,7 +252,7 @@ var Config = ConfigState{Indent: " "}
 //
 //	fmt.Errorf(format, c.NewFormatter(a), c.NewFormatter(b))
 func (c *ConfigState) Errorf(format string, a ...interface{}) (err error) {
-	pv, formatters := formattersGet(c, a)
+	pv, formatters := formattersGet(c, nil, a)
 	defer formattersPut(pv)
 	return fmt.Errorf(format, formatters...)
 }
@@ -127,7 +266,7 @@ func (c *ConfigState) Errorf(format string, a ...interface{}) (err error) {
 //
 //	fmt.Fprint(w, c.NewFormatter(a), c.NewFormatter(b))
 func (c *ConfigState) Fprint(w io.Writer, a ...interface{}) (n int, err error) {
-	pv, formatters := formattersGet(c, a)
+	pv, formatters := formattersGet(c, w, a)
 	defer formattersPut(pv)
 	return fmt.Fprint(w, formatters...)
 }
@@ -141,7 +280,7 @@ func (c *ConfigState) Fprint(w io.Writer, a ...interface{}) (n int, err error) {
 //
 //	fmt.Fprintf(w, format, c.NewFormatter(a), c.NewFormatter(b))
 func (c *ConfigState) Fprintf(w io.Writer, format string, a ...interface{}) (n int, err error) {
-	pv, formatters := formattersGet(c, a)
+	pv, formatters := formattersGet(c, w, a)
 	defer formattersPut(pv)
 	return fmt.Fprintf(w, format, formatters...)
 }
@@ -154,7 +293,7 @@ func (c *ConfigState) Fprintf(w io.Writer, format string, a ...interface{}) (n i
 //
 //	fmt.Fprintln(w, c.NewFormatter(a), c.NewFormatter(b))
 func (c *ConfigState) Fprintln(w io.Writer, a ...interface{}) (n int, err error) {
-	pv, formatters := formattersGet(c, a)
+	pv, formatters := formattersGet(c, w, a)
 	defer formattersPut(pv)
 	return fmt.Fprintln(w, formatters...)
 }
@@ -168,7 +307,7 @@ func (c *ConfigState) Fprintln(w io.Writer, a ...interface{}) (n int, err error)
 //
 //	fmt.Print(c.NewFormatter(a), c.NewFormatter(b))
 func (c *ConfigState) Print(a ...interface{}) (n int, err error) {
-	pv, formatters := formattersGet(c, a)
+	pv, formatters := formattersGet(c, os.Stdout, a)
 	defer formattersPut(pv)
 	return fmt.Print(formatters...)
 }
@@ -182,7 +321,7 @@ func (c *ConfigState) Print(a ...interface{}) (n int, err error) {
 //
 //	fmt.Printf(format, c.NewFormatter(a), c.NewFormatter(b))
 func (c *ConfigState) Printf(format string, a ...interface{}) (n int, err error) {
-	pv, formatters := formattersGet(c, a)
+	pv, formatters := formattersGet(c, os.Stdout, a)
 	defer formattersPut(pv)
 	return fmt.Printf(format, formatters...)
 }
@@ -196,7 +335,7 @@ func (c *ConfigState) Printf(format string, a ...interface{}) (n int, err error)
 //
 //	fmt.Println(c.NewFormatter(a), c.NewFormatter(b))
 func (c *ConfigState) Println(a ...interface{}) (n int, err error) {
-	pv, formatters := formattersGet(c, a)
+	pv, formatters := formattersGet(c, os.Stdout, a)
 	defer formattersPut(pv)
 	return fmt.Println(formatters...)
 }
@@ -209,7 +348,7 @@ func (c *ConfigState) Println(a ...interface{}) (n int, err error) {
 //
 //	fmt.Sprint(c.NewFormatter(a), c.NewFormatter(b))
 func (c *ConfigState) Sprint(a ...interface{}) string {
-	pv, formatters := formattersGet(c, a)
+	pv, formatters := formattersGet(c, nil, a)
 	defer formattersPut(pv)
 	return fmt.Sprint(formatters...)
 }
@@ -222,7 +361,7 @@ func (c *ConfigState) Sprint(a ...interface{}) string {
 //
 //	fmt.Sprintf(format, c.NewFormatter(a), c.NewFormatter(b))
 func (c *ConfigState) Sprintf(format string, a ...interface{}) string {
-	pv, formatters := formattersGet(c, a)
+	pv, formatters := formattersGet(c, nil, a)
 	defer formattersPut(pv)
 	return fmt.Sprintf(format, formatters...)
 }
@@ -235,7 +374,7 @@ func (c *ConfigState) Sprintf(format string, a ...interface{}) string {
 //
 //	fmt.Sprintln(c.NewFormatter(a), c.NewFormatter(b))
 func (c *ConfigState) Sprintln(a ...interface{}) string {
-	pv, formatters := formattersGet(c, a)
+	pv, formatters := formattersGet(c, nil, a)
 	defer formattersPut(pv)
 	return fmt.Sprintln(formatters...)
 }
@@ -261,10 +400,45 @@ func (c *ConfigState) NewFormatter(v interface{}) fmt.Formatter {
 	return newFormatter(c, v)
 }
 
+// NewFormatterContext returns a custom formatter exactly the same as
+// NewFormatter which stops formatting once ctx is done.  The containers being
+// written are closed with a marker of the number of skipped entries and the
+// output ends with a marker of the error of ctx.
+func (c *ConfigState) NewFormatterContext(ctx context.Context, v interface{}) fmt.Formatter {
+	return newFormatterContext(ctx, c, v)
+}
+
 // Fdump formats and displays the passed arguments to io.Writer w.  It formats
 // exactly the same as Dump.
 func (c *ConfigState) Fdump(w io.Writer, a ...interface{}) {
-	fdump(c, w, a...)
+	fdump(context.Background(), c, w, a...)
+}
+
+// FdumpErr formats and displays the passed arguments to io.Writer w exactly
+// the same as Fdump, but stops at the first write that fails.  It returns the
+// number of bytes written and the error of the failed write, if any.
+func (c *ConfigState) FdumpErr(w io.Writer, a ...interface{}) (n int64, err error) {
+	r, err := fdump(context.Background(), c, w, a...)
+	return r.Bytes, err
+}
+
+// FdumpReport formats and displays the passed arguments to io.Writer w
+// exactly the same as FdumpErr and returns a report with the number of bytes
+// written, the counts of the error and Stringer interface methods called and
+// the access paths and stack traces of the ones which panicked.
+func (c *ConfigState) FdumpReport(w io.Writer, a ...interface{}) (DumpReport, error) {
+	return fdump(context.Background(), c, w, a...)
+}
+
+// FdumpContext formats and displays the passed arguments to io.Writer w
+// exactly the same as FdumpErr, but also stops once ctx is done.  The context
+// is checked periodically while the values are traversed.  The containers
+// being written when it's done are closed with a marker of the number of
+// skipped entries and the output ends with a marker of the error of ctx,
+// which is returned as well.
+func (c *ConfigState) FdumpContext(ctx context.Context, w io.Writer, a ...interface{}) (n int64, err error) {
+	r, err := fdump(ctx, c, w, a...)
+	return r.Bytes, err
 }
 
 /*
@@ -291,7 +465,7 @@ See Fdump if you would prefer dumping to an arbitrary io.Writer or Sdump to
 get the formatted result as a string.
 */
 func (c *ConfigState) Dump(a ...interface{}) {
-	fdump(c, os.Stdout, a...)
+	fdump(context.Background(), c, os.Stdout, a...)
 }
 
 // Sdump returns a string with the passed arguments formatted exactly the same
@@ -299,7 +473,264 @@ func (c *ConfigState) Dump(a ...interface{}) {
 func (c *ConfigState) Sdump(a ...interface{}) string {
 	buf := bytesBufferGet()
 	defer bytesBufferPut(buf)
-	fdump(c, buf, a...)
+	fdump(context.Background(), c, buf, a...)
+	return buf.String()
+}
+
+// SdumpContext returns a string with the passed arguments formatted exactly
+// the same as Dump, which stops once ctx is done the same as FdumpContext.  It
+// returns the error of ctx if it stopped early.
+func (c *ConfigState) SdumpContext(ctx context.Context, a ...interface{}) (string, error) {
+	buf := bytesBufferGet()
+	defer bytesBufferPut(buf)
+	_, err := fdump(ctx, c, buf, a...)
+	return buf.String(), err
+}
+
+// FdumpPath dumps the values selected by the path query path inside v to
+// io.Writer w.  It formats exactly the same as DumpPath.
+func (c *ConfigState) FdumpPath(w io.Writer, v interface{}, path string) {
+	fdumpPath(c, w, v, path)
+}
+
+/*
+DumpPath displays only the values selected by the path query path inside v to
+standard out.  Every match is dumped the same way as Dump on its own line
+labeled with its access path:
+
+	Spec.Containers[0].Image: (string) (len=5) "nginx"
+	Spec.Containers[1].Image: (string) (len=5) "redis"
+
+The path is made of selectors such as .Spec and indexes such as [0], ["key"]
+and [key] in Go syntax.  The leading dot is optional and the path "." selects
+v itself.  Selectors resolve struct fields, including unexported and promoted
+fields, and indexes resolve the elements of arrays and slices and the entries
+of maps with string, integer and other keys as written in the paths of Dump
+and Diff.  Pointers and interfaces are followed implicitly.  The wildcard
+selector .* and index [*] select every field, element or entry, so
+"Spec.Containers[*].Image" selects the image of every container.
+
+Fields and map entries which are redacted stay redacted, fields skipped by
+their tags are never selected and the tags of a selected field apply to it.
+Matches are written in the order they are reached, with map keys sorted when
+SortKeys is set.  A marker is written instead when the path doesn't select any
+value or isn't valid.
+
+The configuration options are controlled by modifying the public members
+of c.  See ConfigState for options documentation.
+
+See FdumpPath if you would prefer dumping to an arbitrary io.Writer or
+SdumpPath to get the formatted result as a string.
+*/
+func (c *ConfigState) DumpPath(v interface{}, path string) {
+	fdumpPath(c, os.Stdout, v, path)
+}
+
+// SdumpPath returns a string with the values selected by the path query path
+// inside v formatted exactly the same as DumpPath.
+func (c *ConfigState) SdumpPath(v interface{}, path string) string {
+	buf := bytesBufferGet()
+	defer bytesBufferPut(buf)
+	fdumpPath(c, buf, v, path)
+	return buf.String()
+}
+
+/*
+Fjson writes the passed arguments to io.Writer w as JSON, one document per line.
+The values are walked the same way as Dump and every value is written as an
+object with a stable set of members:
+
+	type      the complete type of the value, such as "*main.Foo"
+	kind      the reflect.Kind of the value, such as "struct" or "ptr"
+	value     scalar values as JSON numbers, booleans or strings and the
+	          dereferenced value of pointers as a nested object
+	len, cap  the length and capacity of arrays, slices, maps, strings and
+	          channels
+	nil       true for nil pointers, slices, maps, interfaces and channels
+	pointers  the chain of pointer addresses used to indirect to the value
+	circular  true for pointers which were already shown
+	method    the result of the error or Stringer interface when invoked
+	elems     the elements of arrays and slices
+	hex       the contents of byte arrays and slices as a hex string
+	entries   the entries of maps as objects with key and value members
+	fields    the fields of structs as objects with name and value members
+	maxDepth  true when the elements are not shown due to MaxDepth
+	redacted  true for values hidden by the redaction options or tags
+
+The configuration options are controlled by modifying the public members
+of c.  See ConfigState for options documentation.
+*/
+func (c *ConfigState) Fjson(w io.Writer, a ...interface{}) {
+	fjson(c, w, a...)
+}
+
+// Sjson returns a string with the passed arguments formatted exactly the same
+// as Fjson.
+func (c *ConfigState) Sjson(a ...interface{}) string {
+	buf := bytesBufferGet()
+	defer bytesBufferPut(buf)
+	fjson(c, buf, a...)
+	return buf.String()
+}
+
+/*
+GoSyntax returns the passed value formatted as a Go expression which evaluates
+to an equal value, for example to turn a captured value into a test fixture.
+
+Composite values are written as composite literals with package-qualified
+types, pointers to composite values as &T{...} and constants are converted to
+their type when it can't be inferred.  Pointers which are shared or circular,
+as well as pointers to values which have no composite literal, are written as
+helper variables that are declared and assigned inside a function literal:
+
+	func() *main.Node {
+		p1 := new(main.Node)
+		*p1 = main.Node{
+			Next: p1,
+		}
+		return p1
+	}()
+
+Fields which hold their zero value are omitted.  Unexported fields of structs
+outside of GoSyntaxPackage can't be set and are reported as comments instead.
+Channels, functions and unsafe pointers have no literal and are written as nil
+with their address as a comment.  Error and Stringer interfaces are never
+invoked.
+
+The configuration options are controlled by modifying the public members
+of c.  See ConfigState for options documentation.
+*/
+func (c *ConfigState) GoSyntax(v interface{}) string {
+	buf := bytesBufferGet()
+	defer bytesBufferPut(buf)
+	fgosyntax(c, buf, v)
+	return buf.String()
+}
+
+// FgoSyntax writes the passed value to io.Writer w formatted exactly the same
+// as GoSyntax.
+func (c *ConfigState) FgoSyntax(w io.Writer, v interface{}) {
+	fgosyntax(c, w, v)
+}
+
+/*
+Fhtml writes the passed arguments to io.Writer w as a self-contained HTML
+document without any external assets.  The values are formatted the same way
+as Dump, but every struct, map, array, slice and pointer is a collapsible node,
+types, lengths and capacities are styled, and circular references link back to
+the first occurrence of the pointer.  Byte arrays and slices are shown in
+hexdump -C fashion.
+
+The configuration options are controlled by modifying the public members
+of c.  See ConfigState for options documentation.
+*/
+func (c *ConfigState) Fhtml(w io.Writer, a ...interface{}) {
+	fhtml(c, w, a...)
+}
+
+/*
+Fyaml writes the passed arguments to io.Writer w as YAML, one document per
+argument.  The values are walked the same way as Dump: structs and maps are
+written as mappings, arrays and slices as sequences, and byte arrays and slices
+as base64 encoded binary data.  Map keys are sorted when SortKeys is set.
+
+Pointers which are reached more than once, such as shared and circular
+pointers, are written with an anchor the first time and as an alias
+afterwards:
+
+	---
+	Name: a
+	Next: &p1
+	  Name: b
+	  Next: *p1
+
+The type of every value is written as a trailing comment when YAMLTypeComments
+is set.  Indentation always uses two spaces since YAML doesn't allow tabs.
+
+The configuration options are controlled by modifying the public members
+of c.  See ConfigState for options documentation.
+*/
+func (c *ConfigState) Fyaml(w io.Writer, a ...interface{}) {
+	fyaml(c, w, a...)
+}
+
+// Syaml returns a string with the passed arguments formatted exactly the same
+// as Fyaml.
+func (c *ConfigState) Syaml(a ...interface{}) string {
+	buf := bytesBufferGet()
+	defer bytesBufferPut(buf)
+	fyaml(c, buf, a...)
+	return buf.String()
+}
+
+/*
+Fflat writes the passed arguments to io.Writer w with every leaf value on its
+own line, prefixed by its access path in Go selector syntax, which makes the
+output easy to grep and to compare line by line:
+
+	Req.Headers["Accept"][0] = "text/html"
+	Items[3].Price = 9.99
+	Next.Next = <already shown at Next>
+
+The values are walked the same way as Dump.  Pointers are dereferenced without
+adding to the path, circular pointers refer to the path they were first
+reached at, and empty arrays, slices, maps and structs are written as a leaf.
+Strings are quoted, byte arrays and slices are written inline according to
+ByteMode, which shows hexdumps as hex strings, and the results of error and
+Stringer interfaces are written as the value.  The path of a top-level value
+is ".".  Map keys are sorted when SortKeys is set.
+
+The configuration options are controlled by modifying the public members
+of c.  See ConfigState for options documentation.
+*/
+func (c *ConfigState) Fflat(w io.Writer, a ...interface{}) {
+	fflat(c, w, a...)
+}
+
+// Sflat returns a string with the passed arguments formatted exactly the same
+// as Fflat.
+func (c *ConfigState) Sflat(a ...interface{}) string {
+	buf := bytesBufferGet()
+	defer bytesBufferPut(buf)
+	fflat(c, buf, a...)
+	return buf.String()
+}
+
+// Fdiff writes the differences between a and b to io.Writer w.  It formats
+// exactly the same as Diff.
+func (c *ConfigState) Fdiff(w io.Writer, a, b interface{}) {
+	fdiff(c, w, a, b)
+}
+
+/*
+Diff displays the structural differences between a and b to standard out.  Both
+values are walked together the same way as Dump and only the subtrees which
+differ are displayed as unified diff hunks of their Dump output.  Each hunk is
+annotated with the access path of the subtree, such as .Items[3].Price, and
+includes a few lines of unchanged context.  Nothing is displayed when the
+values are equal.
+
+Pointers are compared by the values they point to rather than by their
+addresses and circular data structures are detected and handled properly.
+Map entries are matched by key and arrays and slices of different lengths are
+compared as a whole.  Values below MaxDepth are not compared.
+
+The configuration options are controlled by modifying the public members
+of c.  See ConfigState for options documentation.
+
+See Fdiff if you would prefer displaying to an arbitrary io.Writer or Sdiff to
+get the formatted result as a string.
+*/
+func (c *ConfigState) Diff(a, b interface{}) {
+	fdiff(c, os.Stdout, a, b)
+}
+
+// Sdiff returns a string with the differences between a and b formatted
+// exactly the same as Diff.  The string is empty when a and b are equal.
+func (c *ConfigState) Sdiff(a, b interface{}) string {
+	buf := bytesBufferGet()
+	defer bytesBufferPut(buf)
+	fdiff(c, buf, a, b)
 	return buf.String()
 }
 
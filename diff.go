@@ -0,0 +1,514 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change
+// inside of a hunk.
+const diffContextLines = 3
+
+var (
+	diffHeaderBytes = []byte("--- a\n+++ b\n")
+	hunkOpenBytes   = []byte("@@ ")
+	hunkCloseBytes  = []byte(" @@\n")
+)
+
+// diffState contains information about the state of a diff operation.
+type diffState struct {
+	w             io.Writer
+	depth         int
+	wroteHeader   bool
+	ciA, ciB      *cycleInfo
+	cs            *ConfigState
+	renderA       bytes.Buffer
+	renderB       bytes.Buffer
+	renderDump    dumpState
+	renderConfig  ConfigState
+	renderCycles  *cycleInfo
+	methodResultA bytes.Buffer
+	methodResultB bytes.Buffer
+	redacted      bool
+}
+
+// unpackValue returns values inside of non-nil interfaces when possible.
+func (d *diffState) unpackValue(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// render dumps v into buf exactly the same as Dump would starting at the
+// current depth and returns the resulting lines.
+func (d *diffState) render(buf *bytes.Buffer, v reflect.Value) []string {
+	buf.Reset()
+	if !v.IsValid() {
+		// Top-level nil interfaces are displayed the same as fdump does.
+		return []string{string(interfaceBytes) + " " + string(nilAngleBytes)}
+	}
+
+	// Limit the depth of the rendered subtree to what is left of MaxDepth
+	// so the output matches the part of the tree which was compared.
+	d.renderConfig = *d.cs
+	if d.cs.MaxDepth != 0 {
+		d.renderConfig.MaxDepth = d.cs.MaxDepth - d.depth
+	}
+	d.renderCycles.Reset()
+	d.renderDump = dumpState{w: buf, ci: d.renderCycles, cs: &d.renderConfig}
+	if d.redacted {
+		d.renderDump.dumpRedacted(d.unpackValue(v))
+	} else {
+		d.renderDump.dump(d.unpackValue(v))
+	}
+	return strings.Split(buf.String(), "\n")
+}
+
+// equalRender reports whether a and b dump to the same output.
+func (d *diffState) equalRender(a, b reflect.Value) bool {
+	la := d.render(&d.renderA, a)
+	lb := d.render(&d.renderB, b)
+	if len(la) != len(lb) {
+		return false
+	}
+	for i := range la {
+		if la[i] != lb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hunk writes the line differences between the dumps of a and b, which are
+// both located at path, as one or more unified diff hunks.  Either value may
+// be the zero reflect.Value to indicate that it is missing on that side.
+func (d *diffState) hunk(path string, a, b reflect.Value, hasA, hasB bool) {
+	var la, lb []string
+	if hasA {
+		la = d.render(&d.renderA, a)
+	}
+	if hasB {
+		lb = d.render(&d.renderB, b)
+	}
+	ops := diffLines(la, lb)
+
+	// Find the ranges of operations which contain changes including their
+	// surrounding context and write each of them.
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		start := i - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(ops) && j <= end+2*diffContextLines; j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			}
+		}
+		end += diffContextLines + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+		d.writeHunk(path, ops[start:end])
+		i = end
+	}
+}
+
+// writeHunk writes a single hunk for path consisting of ops.
+func (d *diffState) writeHunk(path string, ops []diffOp) {
+	if !d.wroteHeader {
+		d.w.Write(diffHeaderBytes)
+		d.wroteHeader = true
+	}
+	d.w.Write(hunkOpenBytes)
+	io.WriteString(d.w, path)
+	d.w.Write(hunkCloseBytes)
+	for _, op := range ops {
+		switch op.kind {
+		case '-':
+			d.w.Write(minusBytes)
+		case '+':
+			d.w.Write(plusBytes)
+		default:
+			d.w.Write(spaceBytes)
+		}
+		io.WriteString(d.w, op.line)
+		d.w.Write(newlineBytes)
+	}
+}
+
+// diffMethods compares the results of the error and Stringer interfaces of a
+// and b when method invocation is enabled.  It returns whether the caller
+// should stop descending into the values.
+func (d *diffState) diffMethods(path string, a, b reflect.Value) (done bool) {
+	if d.cs.DisableMethods {
+		return false
+	}
+	kind := a.Kind()
+	if kind == reflect.Invalid || kind == reflect.Interface {
+		return false
+	}
+	d.methodResultA.Reset()
+	d.methodResultB.Reset()
+	handledA := handleMethods(d.cs, &d.methodResultA, a, nil)
+	handledB := handleMethods(d.cs, &d.methodResultB, b, nil)
+	if handledA != handledB ||
+		!bytes.Equal(d.methodResultA.Bytes(), d.methodResultB.Bytes()) {
+		d.hunk(path, a, b, true, true)
+		return true
+	}
+	return handledA
+}
+
+// diffPtr compares the values pointed to by a and b by indirecting them as
+// necessary while detecting circular references on both sides.
+func (d *diffState) diffPtr(path string, a, b reflect.Value) {
+	va := derefPtr(a, d.depth, d.ciA)
+	nilA, cycleA, indirectsA := d.ciA.nilFound, d.ciA.cycleFound, d.ciA.indirects
+	vb := derefPtr(b, d.depth, d.ciB)
+	nilB, cycleB, indirectsB := d.ciB.nilFound, d.ciB.cycleFound, d.ciB.indirects
+
+	switch {
+	case nilA && nilB, cycleA && cycleB:
+		if indirectsA != indirectsB {
+			d.hunk(path, a, b, true, true)
+		}
+
+	case nilA, nilB, cycleA, cycleB, indirectsA != indirectsB, va.Type() != vb.Type():
+		d.hunk(path, a, b, true, true)
+
+	default:
+		d.diffValue(path, va, vb, a, b)
+	}
+}
+
+// diff compares a and b located at path and writes hunks for every subtree
+// that differs.
+func (d *diffState) diff(path string, a, b reflect.Value) {
+	ua, ub := d.unpackValue(a), d.unpackValue(b)
+	switch {
+	case !ua.IsValid() || !ub.IsValid():
+		if ua.IsValid() != ub.IsValid() {
+			d.hunk(path, a, b, true, true)
+		}
+		return
+
+	case ua.Type() != ub.Type():
+		d.hunk(path, a, b, true, true)
+		return
+
+	case ua.Kind() == reflect.Ptr:
+		d.diffPtr(path, ua, ub)
+		return
+	}
+	d.diffValue(path, ua, ub, a, b)
+}
+
+// diffValue compares the non-pointer values a and b of the same type.  The
+// original values origA and origB are used when the difference is written so
+// the output includes any pointer indirection leading to them.
+func (d *diffState) diffValue(path string, a, b, origA, origB reflect.Value) {
+	if d.diffMethods(path, a, b) {
+		return
+	}
+
+	switch a.Kind() {
+	case reflect.Interface:
+		// Only nil interfaces are left at this point.
+		if a.IsNil() != b.IsNil() {
+			d.hunk(path, origA, origB, true, true)
+		}
+
+	case reflect.Array, reflect.Slice:
+		if a.Kind() == reflect.Slice && a.IsNil() != b.IsNil() ||
+			a.Len() != b.Len() || isByteSlice(d.cs, a) {
+			if !d.equalRender(origA, origB) {
+				d.hunk(path, origA, origB, true, true)
+			}
+			return
+		}
+		if d.enter() {
+			for i := 0; i < a.Len(); i++ {
+				d.diff(indexPath(path, i), a.Index(i), b.Index(i))
+			}
+		}
+		d.depth--
+
+	case reflect.Map:
+		if a.IsNil() != b.IsNil() {
+			d.hunk(path, origA, origB, true, true)
+			return
+		}
+		if d.enter() {
+			d.diffMap(path, a, b)
+		}
+		d.depth--
+
+	case reflect.Struct:
+		if d.enter() {
+			vt := a.Type()
+			for i, o := range fieldOptionsOf(vt) {
+				if o.skip || o.omitEmpty && a.Field(i).IsZero() && b.Field(i).IsZero() {
+					continue
+				}
+				name := vt.Field(i).Name
+				if d.cs.redactsField(o, name) {
+					d.diffRedacted(fieldPath(path, name), a.Field(i), b.Field(i), true, true)
+					continue
+				}
+				d.diff(fieldPath(path, name), a.Field(i), b.Field(i))
+			}
+		}
+		d.depth--
+
+	default:
+		if !d.equalRender(a, b) {
+			d.hunk(path, origA, origB, true, true)
+		}
+	}
+}
+
+// enter increases the depth for the elements of a container and returns
+// whether they are within the configured maximum depth.
+func (d *diffState) enter() bool {
+	d.depth++
+	return d.cs.MaxDepth == 0 || d.depth <= d.cs.MaxDepth
+}
+
+// diffMap compares the entries of the maps a and b by key.  Entries that only
+// exist on one side are written as removed or added.
+func (d *diffState) diffMap(path string, a, b reflect.Value) {
+	keys := a.MapKeys()
+	for _, key := range b.MapKeys() {
+		if !a.MapIndex(key).IsValid() {
+			keys = append(keys, key)
+		}
+	}
+	if d.cs.SortKeys {
+		sortValues(keys, d.cs)
+	}
+	for _, key := range keys {
+		va, vb := a.MapIndex(key), b.MapIndex(key)
+		kp := keyPath(d.cs, path, key)
+		if d.cs.redactsKey(key) {
+			d.diffRedacted(kp, va, vb, va.IsValid(), vb.IsValid())
+			continue
+		}
+		if !va.IsValid() || !vb.IsValid() {
+			d.hunk(kp, va, vb, va.IsValid(), vb.IsValid())
+			continue
+		}
+		d.diff(kp, va, vb)
+	}
+}
+
+// diffRedacted compares the markers of the redacted values a and b, so only
+// differences in their types and lengths are written.
+func (d *diffState) diffRedacted(path string, a, b reflect.Value, hasA, hasB bool) {
+	d.redacted = true
+	if !hasA || !hasB || !d.equalRender(a, b) {
+		d.hunk(path, a, b, hasA, hasB)
+	}
+	d.redacted = false
+}
+
+// isByteSlice returns whether the array or slice v holds bytes, which Dump
+// displays as a single value such as a hexdump or, depending on the ByteMode
+// option, a string.  Such values are compared as a whole instead of per
+// element.
+func isByteSlice(cs *ConfigState, v reflect.Value) bool {
+	_, ok := byteSliceOf(cs, v)
+	return ok
+}
+
+// diffOp is a single line of a line based diff.  The kind is ' ' for lines
+// which are unchanged, '-' for removed lines and '+' for added lines.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// diffLines returns the shortest edit script which turns the lines a into the
+// lines b using the linear space variant of the Myers difference algorithm.
+func diffLines(a, b []string) []diffOp {
+	return appendDiff(make([]diffOp, 0, len(a)+len(b)), a, b)
+}
+
+// appendDiff appends the edit script which turns the lines a into the lines b
+// to ops.
+func appendDiff(ops []diffOp, a, b []string) []diffOp {
+	// Strip the common prefix and suffix since they are unchanged anyway,
+	// which keeps the work of the algorithm proportional to the changes.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if x, y, ok := middleSnake(ma, mb); ok {
+		ops = appendDiff(ops, ma[:x], mb[:y])
+		ops = appendDiff(ops, ma[x:], mb[y:])
+	} else {
+		for _, line := range ma {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range mb {
+			ops = append(ops, diffOp{'+', line})
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// middleSnake searches the furthest reaching paths from the start and from the
+// end of a and b at the same time until they overlap, which they do in the
+// middle of a shortest edit script.  It returns the point x, y where the edit
+// script can be split, so the halves can be diffed on their own in linear
+// space.  The lines are replaced as a whole when there's no such point, which
+// is the case when a or b is empty or they have no lines in common.
+func middleSnake(a, b []string) (x, y int, ok bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	vf := make([]int, 2*maxD+2)
+	vb := make([]int, 2*maxD+2)
+	for i := range vf {
+		vf[i] = -1
+		vb[i] = -1
+	}
+	vf[offset+1] = 0
+	vb[offset+1] = 0
+
+	// The paths overlap on a forward step when the difference of the lengths
+	// is odd and on a backward step otherwise.  Diagonals which ran off the
+	// edges are trimmed from the search.
+	delta := n - m
+	front := delta%2 != 0
+	var fStart, fEnd, bStart, bEnd int
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			var x1 int
+			if k == -d || k != d && vf[offset+k-1] < vf[offset+k+1] {
+				x1 = vf[offset+k+1]
+			} else {
+				x1 = vf[offset+k-1] + 1
+			}
+			y1 := x1 - k
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			vf[offset+k] = x1
+			switch {
+			case x1 > n:
+				fEnd += 2
+			case y1 > m:
+				fStart += 2
+			case front:
+				kb := offset + delta - k
+				if kb >= 0 && kb < len(vb) && vb[kb] != -1 && x1 >= n-vb[kb] {
+					return x1, y1, true
+				}
+			}
+		}
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			var x2 int
+			if k == -d || k != d && vb[offset+k-1] < vb[offset+k+1] {
+				x2 = vb[offset+k+1]
+			} else {
+				x2 = vb[offset+k-1] + 1
+			}
+			y2 := x2 - k
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			vb[offset+k] = x2
+			switch {
+			case x2 > n:
+				bEnd += 2
+			case y2 > m:
+				bStart += 2
+			case !front:
+				kf := offset + delta - k
+				if kf >= 0 && kf < len(vf) && vf[kf] != -1 {
+					x1 := vf[kf]
+					if x1 >= n-x2 {
+						return x1, offset + x1 - kf, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// fdiff is a helper function to consolidate the logic from the various public
+// methods which take varying writers and config states.
+func fdiff(cs *ConfigState, w io.Writer, a, b interface{}) {
+	d := diffStatePool.Get().(*diffState)
+	defer diffStatePut(d)
+	d.w = w
+	d.cs = cs
+	d.ciA = cycleInfoGet()
+	d.ciB = cycleInfoGet()
+	d.renderCycles = cycleInfoGet()
+
+	d.diff(rootPath, reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+var diffStatePool = sync.Pool{New: func() interface{} {
+	return new(diffState)
+}}
+
+func diffStatePut(d *diffState) {
+	cycleInfoPut(d.ciA)
+	cycleInfoPut(d.ciB)
+	cycleInfoPut(d.renderCycles)
+	d.w = nil
+	d.depth = 0
+	d.wroteHeader = false
+	d.ciA, d.ciB, d.renderCycles = nil, nil, nil
+	d.cs = nil
+	d.renderDump = dumpState{}
+	d.renderConfig = ConfigState{}
+	diffStatePool.Put(d)
+}
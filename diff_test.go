@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spewerspew/spew"
+)
+
+// diffTest is used to describe a test to be performed against Diff with the
+// config cs.
+type diffTest struct {
+	cs   *spew.ConfigState
+	a, b interface{}
+	want string
+}
+
+// diffTests houses the tests to be performed against Diff.
+var diffTests []diffTest
+
+// addDiffTest is a helper method to append the passed config, inputs and
+// desired result to diffTests.
+func addDiffTest(cs *spew.ConfigState, a, b interface{}, want string) {
+	diffTests = append(diffTests, diffTest{cs, a, b, want})
+}
+
+func addScalarDiffTests() {
+	cs := &spew.ConfigState{Indent: " "}
+	addDiffTest(cs, 1, 1, "")
+	addDiffTest(cs, nil, nil, "")
+	addDiffTest(cs, 1, 2, "--- a\n+++ b\n@@ . @@\n-(int) 1\n+(int) 2\n")
+	addDiffTest(cs, 1, "1", "--- a\n+++ b\n@@ . @@\n-(int) 1\n"+
+		"+(string) (len=1) \"1\"\n")
+	addDiffTest(cs, nil, 1, "--- a\n+++ b\n@@ . @@\n"+
+		"-(interface {}) <nil>\n+(int) 1\n")
+}
+
+func addContainerDiffTests() {
+	type diffStruct struct {
+		Name  string
+		Price float64
+		Tags  []string
+		id    int
+	}
+	cs := &spew.ConfigState{Indent: " ", SortKeys: true}
+	tags := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i"}
+	tags2 := append([]string(nil), tags...)
+	tags2[0] = "A"
+	tags2[8] = "I"
+
+	addDiffTest(cs, diffStruct{Name: "x", Price: 1}, diffStruct{Name: "x", Price: 2},
+		"--- a\n+++ b\n@@ .Price @@\n-(float64) 1\n+(float64) 2\n")
+	addDiffTest(cs, diffStruct{id: 1}, diffStruct{id: 2},
+		"--- a\n+++ b\n@@ .id @@\n-(int) 1\n+(int) 2\n")
+	addDiffTest(cs, []diffStruct{{Name: "x"}}, []diffStruct{{Name: "y"}},
+		"--- a\n+++ b\n@@ .[0].Name @@\n"+
+			"-(string) (len=1) \"x\"\n+(string) (len=1) \"y\"\n")
+	addDiffTest(cs, omitted{A: 1, Skip: 2}, omitted{A: 1, Skip: 3}, "")
+	addDiffTest(cs, omitted{}, omitted{Opt: []int{1}},
+		"--- a\n+++ b\n@@ .Opt @@\n-([]int) <nil>\n+([]int) (len=1 cap=1) {\n+ (int) 1\n+}\n")
+	addDiffTest(cs, map[string]int{"a": 1, "b": 2}, map[string]int{"b": 3, "c": 4},
+		"--- a\n+++ b\n@@ .[\"a\"] @@\n-(int) 1\n"+
+			"@@ .[\"b\"] @@\n-(int) 2\n+(int) 3\n"+
+			"@@ .[\"c\"] @@\n+(int) 4\n")
+	addDiffTest(cs, map[int]interface{}{1: true}, map[int]interface{}{1: nil},
+		"--- a\n+++ b\n@@ .[1] @@\n-(bool) true\n+(interface {}) <nil>\n")
+	addDiffTest(cs, []int{1}, []int{1, 2},
+		"--- a\n+++ b\n@@ . @@\n"+
+			"-([]int) (len=1 cap=1) {\n"+
+			"- (int) 1\n"+
+			"+([]int) (len=2 cap=2) {\n"+
+			"+ (int) 1,\n"+
+			"+ (int) 2\n"+
+			" }\n")
+	addDiffTest(cs, diffStruct{Tags: tags}, diffStruct{Tags: tags2},
+		"--- a\n+++ b\n@@ .Tags[0] @@\n"+
+			"-(string) (len=1) \"a\"\n+(string) (len=1) \"A\"\n"+
+			"@@ .Tags[8] @@\n"+
+			"-(string) (len=1) \"i\"\n+(string) (len=1) \"I\"\n")
+	addDiffTest(cs, []byte("abc"), []byte("abd"),
+		"--- a\n+++ b\n@@ . @@\n"+
+			" ([]uint8) (len=3 cap=3) {\n"+
+			"- 00000000  61 62 63                                          |abc|\n"+
+			"+ 00000000  61 62 64                                          |abd|\n"+
+			" }\n")
+	addDiffTest(&spew.ConfigState{Indent: " ", MaxDepth: 1}, [][]int{{1}}, [][]int{{2}}, "")
+}
+
+func addPointerDiffTests() {
+	cs := &spew.ConfigState{Indent: " ", DisablePointerAddresses: true}
+	addDiffTest(cs, nodeCycle(), nodeCycle(), "")
+	addDiffTest(cs, nodeCycle(), &node{Name: "a", Next: &node{Name: "c"}},
+		"--- a\n+++ b\n@@ .Next.Name @@\n"+
+			"-(string) (len=1) \"b\"\n+(string) (len=1) \"c\"\n"+
+			"@@ .Next.Next @@\n"+
+			"-(*spew_test.node)({\n"+
+			"- Name: (string) (len=1) \"a\",\n"+
+			"- Next: (*spew_test.node)({\n"+
+			"-  Name: (string) (len=1) \"b\",\n"+
+			"-  Next: (*spew_test.node)(<already shown>)\n"+
+			"- })\n"+
+			"-})\n"+
+			"+(*spew_test.node)(<nil>)\n")
+	addDiffTest(cs, &node{}, (*node)(nil),
+		"--- a\n+++ b\n@@ . @@\n"+
+			"-(*spew_test.node)({\n"+
+			"- Name: (string) \"\",\n"+
+			"- Next: (*spew_test.node)(<nil>)\n"+
+			"-})\n"+
+			"+(*spew_test.node)(<nil>)\n")
+}
+
+func addMethodDiffTests() {
+	addDiffTest(&spew.ConfigState{Indent: " "}, stringer("a"), stringer("b"),
+		"--- a\n+++ b\n@@ . @@\n"+
+			"-(spew_test.stringer) (len=1) stringer a\n"+
+			"+(spew_test.stringer) (len=1) stringer b\n")
+	addDiffTest(&spew.ConfigState{Indent: " ", DisableMethods: true}, customError(1), customError(2),
+		"--- a\n+++ b\n@@ . @@\n"+
+			"-(spew_test.customError) 1\n+(spew_test.customError) 2\n")
+}
+
+func setupDiffTests() {
+	if len(diffTests) == 0 {
+		addScalarDiffTests()
+		addContainerDiffTests()
+		addPointerDiffTests()
+		addMethodDiffTests()
+	}
+}
+
+// TestDiff executes all of the tests described by diffTests and ensures Diff
+// only writes the subtrees which differ.
+func TestDiff(t *testing.T) {
+	setupDiffTests()
+
+	t.Logf("Running %d tests", len(diffTests))
+	for i, test := range diffTests {
+		buf := new(bytes.Buffer)
+		test.cs.Fdiff(buf, test.a, test.b)
+		s := buf.String()
+		if s != test.want {
+			t.Errorf("Diff #%d\n got: %s want: %s", i, s, test.want)
+			continue
+		}
+		if s2 := test.cs.Sdiff(test.a, test.b); s2 != s {
+			t.Errorf("Sdiff #%d\n got: %s want: %s", i, s2, s)
+		}
+	}
+}
@@ -23,6 +23,9 @@ printing facilities for Go data types are as follows:
 
 	* Pointers are dereferenced and followed
 	* Circular data structures are detected and handled properly
+	* Deeply nested data structures, such as long linked lists, are printed
+	  without exhausting the goroutine stack (only when using Dump style or
+	  the custom Formatter)
 	* Custom Stringer/error interfaces are optionally invoked, including
 	  on unexported types
 	* Custom types which only implement the Stringer/error interfaces via
@@ -82,6 +85,52 @@ The following configuration options are available:
 		Maximum number of levels to descend into nested data structures.
 		There is no limit by default.
 
+	* MaxElements
+		Maximum number of elements of arrays and slices, entries of maps
+		and bytes of hexdumps to print, followed by a marker with the
+		number of omitted ones.  There is no limit by default.
+
+	* TailElements
+		Number of trailing elements, entries or bytes to print after the
+		marker in addition to the leading MaxElements ones.
+
+	* MaxStringLen
+		Maximum number of bytes of strings to print, followed by a marker
+		with the number of omitted bytes.  There is no limit by default.
+
+	* MaxBytes and MaxNodes
+		Maximum number of bytes and values written by each call of Dump or
+		by each value of the custom formatter.  Once either is reached, the
+		entries left of the containers being written are replaced by a
+		marker with their number and the output is closed properly.  There
+		is no limit by default.
+
+	* ReferenceLabels
+		Labels the pointers which are reached more than once, such as
+		shared and circular pointers.  Their first occurrence is written
+		as &N followed by the value and later ones as *N, so shared
+		values are written once and cycles refer to their target.
+		Pointers are not labeled by default.
+
+	* LineWidth
+		Target width of the lines written by Dump.  Arrays, slices, maps
+		and structs which fit on the rest of their line are written
+		inline in the style of the custom formatter and only larger ones
+		are expanded.  spew.LineWidthTerminal uses the width of the
+		terminal.  Every value is expanded by default.
+
+	* HexDump
+		Layout of the hexdumps of byte arrays and slices: the number of
+		bytes per line, the size of byte groups, uppercase digits, the
+		width of offsets, a base offset added to offsets and an ASCII,
+		UTF-8 or no right-hand column.  The default matches hexdump -C.
+
+	* ByteMode
+		How byte arrays and slices are printed: as hexdumps, as quoted strings
+		when they are valid UTF-8, base64, compact hex strings or
+		automatically chosen by their contents and size.  By default
+		Dump prints hexdumps and the custom formatter lists of numbers.
+
 	* DisableMethods
 		Disables invocation of error and Stringer interface methods.
 		Method invocation is enabled by default.
@@ -95,6 +144,12 @@ The following configuration options are available:
 		DisablePointerAddresses specifies whether to disable the printing of
 		pointer addresses. This is useful when diffing data structures in tests.
 
+	* SymbolicPointers
+		Replaces pointer addresses with IDs such as p1 and p2, assigned to
+		distinct addresses in the order they are written.  This keeps
+		output stable across runs, like DisablePointerAddresses, while
+		equal pointers are still recognizable.
+
 	* DisableCapacities
 		DisableCapacities specifies whether to disable the printing of
 		capacities for arrays, slices, maps and channels. This is useful when
@@ -104,6 +159,30 @@ The following configuration options are available:
 		Enables recursion into types after invoking error and Stringer interface
 		methods. Recursion after method invocation is disabled by default.
 
+	* MethodTimeout
+		Maximum time to wait for each error and Stringer interface method.
+		Calls which don't return in time are displayed as <method timed out>
+		followed by the value itself.  Methods which time out keep running
+		in the background, where they may still access their receiver.
+		Methods are waited for indefinitely by default.
+
+	* MethodPolicy
+		Restricts the error and Stringer interfaces invoked to the types or
+		packages its Allow and Deny lists match, such as to avoid methods
+		which query a database.  The methods of all types are invoked by
+		default.
+
+	* NilMethods
+		Specifies whether the error and Stringer interfaces of nil pointers
+		are skipped, invoked or skipped with a marker naming the method.
+		They are skipped by default.
+
+	* DetectMethodReentry
+		Keeps error and Stringer interface methods which print their own
+		value with spew from being invoked again by the nested call, which
+		prints the value as if it had no methods instead.  Detection is
+		disabled by default since it slows down method invocation.
+
 	* SortKeys
 		Specifies map keys should be sorted before being printed. Use
 		this to have a more deterministic, diffable output.  Note that
@@ -118,6 +197,36 @@ The following configuration options are available:
 		spewed to strings and sorted by those strings.  This is only
 		considered if SortKeys is true.
 
+	* GoSyntaxPackage
+		Import path of the package the output of GoSyntax is compiled in.
+		Types of that package are not qualified and their unexported fields
+		are included.  Every package is treated as foreign by default.
+
+	* YAMLTypeComments
+		Specifies that Fyaml writes the type of every value as a trailing
+		comment.  Types are omitted by default.
+
+	* RedactPolicy
+		Patterns of struct field names and string map keys, in Fields, and
+		a regular expression, in Pattern, which select the values replaced
+		by a marker showing only their type and length in the output of
+		every renderer.  The patterns use the syntax of path.Match and are
+		matched case-insensitively.  Nothing is redacted by default.  Use
+		spew.DefaultRedactFields to hide commonly used names such as
+		*password*, *token* and Authorization.
+
+	* Theme
+		Specifies the colors used to highlight type names, field names,
+		strings, numbers, nil and circular reference markers written by
+		Dump and the custom formatter.  Colors are disabled by default.
+		Output is only colored when it's written to a terminal and the
+		NO_COLOR environment variable is empty.  Use &spew.DefaultTheme or
+		define your own spew.Theme.
+
+	* ForceColor
+		Specifies that output is colored according to Theme even if it
+		isn't written to a terminal, such as the output of Sdump.
+
 Dump Usage
 
 Simply call spew.Dump with a list of variables you want to dump:
@@ -129,6 +238,23 @@ io.Writer.  For example, to dump to standard error:
 
 	spew.Fdump(os.Stderr, myVar1, myVar2, ...)
 
+Fdump ignores errors from the writer.  Use spew.FdumpErr instead to stop at
+the first failed write and get the number of bytes written and the error:
+
+	n, err := spew.FdumpErr(pipe, myVar1, myVar2, ...)
+
+spew.FdumpReport additionally returns the counts of the error and Stringer
+interface methods called while dumping and of the ones which panicked or timed
+out.  The access path and stack trace of each panic are reported as well, so
+broken methods can be tracked down in large dumps.
+
+To stop dumping once a context is done, such as on the deadline of a
+request, call spew.FdumpContext or spew.SdumpContext.  The output is closed
+properly and ends with a marker of the error of the context, which is returned
+as well.  spew.NewFormatterContext does the same for the custom formatter:
+
+	str, err := spew.SdumpContext(ctx, myVar1, myVar2, ...)
+
 A third option is to call spew.Sdump to get the formatted output as a string:
 
 	str := spew.Sdump(myVar1, myVar2, ...)
@@ -156,6 +282,164 @@ command as shown.
 	 00000020  31 32                                             |12|
 	}
 
+Diff Usage
+
+To display only the parts of two values which differ, for example to explain
+why a test failed, call spew.Diff, spew.Fdiff, or spew.Sdiff:
+
+	spew.Diff(want, got)
+	spew.Fdiff(os.Stderr, want, got)
+	str := spew.Sdiff(want, got)
+
+Both values are walked the same way as Dump and every differing subtree is
+displayed as a unified diff hunk of its Dump output, annotated with its access
+path:
+
+	--- a
+	+++ b
+	@@ .Items[0].Price @@
+	-(float64) 1
+	+(float64) 1.5
+
+The result of spew.Sdiff is empty when the values are equal.
+
+JSON Usage
+
+To feed dumps into tools which only accept JSON, call spew.Fjson or spew.Sjson.
+Every argument is written as a single line JSON document.  The values are walked
+the same way as Dump and each one becomes an object with members such as type,
+kind, len, cap, pointers and circular which can be queried with tools like jq:
+
+	spew.Fjson(os.Stderr, myVar1, myVar2, ...)
+	str := spew.Sjson(myVar1, myVar2, ...)
+
+See ConfigState.Fjson for the complete list of members.
+
+HTML Usage
+
+Large dumps are easier to explore in a browser.  Call spew.Fhtml to write a
+self-contained HTML document in which every struct, map, array, slice and
+pointer can be collapsed and circular references link back to the first
+occurrence of the pointer:
+
+	spew.Fhtml(someWriter, myVar1, myVar2, ...)
+
+YAML Usage
+
+To produce a readable dump for humans and YAML tooling alike, call spew.Fyaml
+or spew.Syaml.  Every argument is written as a separate YAML document:
+
+	spew.Fyaml(os.Stderr, myVar1, myVar2, ...)
+	str := spew.Syaml(myVar1, myVar2, ...)
+
+Shared and circular pointers are written with an anchor the first time they
+are reached and as an alias afterwards, so the output never repeats itself.
+
+Path Usage
+
+When only one branch of a large value is of interest, call spew.DumpPath,
+spew.FdumpPath or spew.SdumpPath with a path in Go selector syntax.  The
+wildcard [*] selects every element or entry and .* every field:
+
+	spew.DumpPath(state, "Spec.Containers[0].Env")
+	str := spew.SdumpPath(state, "Spec.Containers[*].Image")
+
+Every match is dumped the same way as Dump and labeled with its path:
+
+	Spec.Containers[0].Image: (string) (len=5) "nginx"
+	Spec.Containers[1].Image: (string) (len=5) "redis"
+
+Flat Usage
+
+To grep a dump or compare it line by line, call spew.Fflat or spew.Sflat.
+Every leaf value is written on its own line prefixed by its access path:
+
+	spew.Fflat(os.Stderr, myVar1, myVar2, ...)
+	str := spew.Sflat(myVar1, myVar2, ...)
+
+Sample output:
+
+	Req.Headers["Accept"][0] = "text/html"
+	Items[3].Price = 9.99
+
+Circular pointers are written as a reference to the path of the value they
+point to.
+
+Go Syntax Usage
+
+To turn a captured value into a test fixture, call spew.GoSyntax to get a Go
+expression which evaluates to an equal value:
+
+	fixture := spew.GoSyntax(myVar)
+
+Shared and circular pointers are written as helper variables inside a function
+literal and unexported fields of foreign packages are reported as comments.
+
+Struct Tags
+
+The way Dump and the custom formatter print a struct field can be controlled
+with a spew struct field tag holding a comma separated list of directives:
+
+	type Request struct {
+		Secret  string `spew:"redact"`
+		Cache   *cache `spew:"-"`
+		Flags   uint32 `spew:"hex"`
+		Comment string `spew:"omitempty,maxlen=80"`
+		Body    []byte `spew:"bytes=string"`
+		Parent  *Node  `spew:"depth=1"`
+	}
+
+The supported directives are:
+
+	* -
+		Skips the field.
+
+	* redact
+		Prints a marker instead of the value.
+
+	* hex
+		Prints integers and strings in hexadecimal.
+
+	* omitempty
+		Skips the field when it holds the zero value of its type.
+
+	* maxlen=N
+		Prints at most N bytes of strings and N elements of arrays, slices
+		and maps followed by the number of omitted bytes or elements.
+
+	* depth=N
+		Descends at most N levels into the value.
+
+	* bytes=M
+		Prints byte arrays and slices in mode M instead of the ByteMode
+		option, which is one of hexdump, string, base64, hex and auto.
+
+Fields can also be redacted by name, along with string map keys, for values
+which don't have tags with the RedactPolicy option:
+
+	spew.Config.RedactPolicy = &spew.RedactPolicy{Fields: spew.DefaultRedactFields}
+
+Except for depth, the directives apply to the value of the field and the
+elements of the arrays, slices and maps it holds, but not to the fields of
+nested structs, which have their own tags.  The - and omitempty directives
+leave fields out of the JSON, YAML, HTML, Go syntax and diff output as well.
+
+Registered Formatters
+
+Values of types which can't be given error or Stringer methods, such as types
+of third-party packages, can be written by a function registered for their type
+or for an interface they implement:
+
+	spew.RegisterFormatter(reflect.TypeOf(time.Duration(0)),
+		func(w io.Writer, v reflect.Value, s *spew.ValueState) {
+			io.WriteString(w, time.Duration(v.Int()).String())
+		})
+
+Dump and the custom formatter call the function instead of printing the value
+themselves.  The ValueState passed to it holds the depth and indentation of the
+value and writes nested values with the same circular reference detection as
+the rest of the output.
+
 Custom Formatter
 
 Spew provides a custom formatter that implements the fmt.Formatter interface
@@ -18,6 +18,7 @@
 package spew
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"reflect"
@@ -62,11 +63,23 @@ var indentCache sync.Map // map[indentCacheKey]string
 // dumpState contains information about the state of a dump operation.
 type dumpState struct {
 	w                io.Writer
+	out              errWriter
+	budget           outputBudget
+	stats            methodStats
+	inl              *formatState
 	depth            int
 	ignoreNextType   bool
 	ignoreNextIndent bool
 	ci               *cycleInfo
 	cs               *ConfigState
+	theme            *Theme
+	field            fieldState
+	col              columnWriter
+	width            int
+	labels           pointerLabels
+	ids              pointerIDs
+	stack            walkStack
+	fstack           walkStack
 }
 
 // indent performs indentation according to the depth level and cs.Indent
@@ -76,9 +89,21 @@ func (d *dumpState) indent() {
 		d.ignoreNextIndent = false
 		return
 	}
-	for i := 0; i < d.depth; i++ {
-		io.WriteString(d.w, d.cs.Indent)
+	if d.cs.Indent == "" || d.depth == 0 {
+		return
+	}
+	io.WriteString(d.w, d.indentString())
+}
+
+// indentString returns the indentation of the current depth level.
+func (d *dumpState) indentString() string {
+	key := indentCacheKey{d.cs.Indent, d.depth}
+	if cv, ok := indentCache.Load(key); ok {
+		return cv.(string)
 	}
+	indent := strings.Repeat(d.cs.Indent, d.depth)
+	indentCache.Store(key, indent)
+	return indent
 }
 
 // unpackValue returns values inside of non-nil interfaces when possible.
@@ -99,12 +124,14 @@ func (d *dumpState) dumpPtr(v reflect.Value) {
 	ve := derefPtr(v, d.depth, d.ci)
 
 	// Display type information.
+	d.theme.start(d.w, tokenType)
 	d.w.Write(openParenBytes)
 	for i := 0; i < d.ci.indirects; i++ {
 		d.w.Write(asteriskBytes)
 	}
 	io.WriteString(d.w, ve.Type().String())
 	d.w.Write(closeParenBytes)
+	d.theme.end(d.w, tokenType)
 
 	// Display pointer information.
 	if !d.cs.DisablePointerAddresses && len(d.ci.pointerChain) > 0 {
@@ -113,7 +140,9 @@ func (d *dumpState) dumpPtr(v reflect.Value) {
 			if i > 0 {
 				d.w.Write(pointerChainBytes)
 			}
-			printHexPtr(d.w, addr)
+			d.theme.start(d.w, tokenPointer)
+			d.ids.print(d.w, addr)
+			d.theme.end(d.w, tokenPointer)
 		}
 		d.w.Write(closeParenBytes)
 	}
@@ -122,123 +151,333 @@ func (d *dumpState) dumpPtr(v reflect.Value) {
 	d.w.Write(openParenBytes)
 	switch {
 	case d.ci.nilFound:
-		d.w.Write(nilAngleBytes)
+		if !handleNilMethods(d.cs, d.w, ve, &d.stats, d.theme) {
+			d.theme.writeToken(d.w, tokenNil, nilAngleBytes)
+		}
 
 	case d.ci.cycleFound:
-		d.w.Write(circularBytes)
+		if n, ok := d.labels.labels[pointerKey(ve)]; ok {
+			printLabel(d.w, '*', n, d.theme)
+			break
+		}
+		d.theme.writeToken(d.w, tokenCircular, circularBytes)
 
 	default:
+		if d.labels.enabled() {
+			key := ptrKey{d.ci.pointerChain[len(d.ci.pointerChain)-1], ve.Type()}
+			n, seen := d.labels.label(key)
+			if seen {
+				printLabel(d.w, '*', n, d.theme)
+				break
+			}
+			if n != 0 {
+				printLabel(d.w, '&', n, d.theme)
+				d.w.Write(spaceBytes)
+			}
+		}
+		// The closing parenthesis is written once the value is.
+		d.stack.push(reflect.Ptr, v)
 		d.ignoreNextType = true
-		d.dump(ve)
+		d.dumpValue(ve)
+		return
 	}
 	d.w.Write(closeParenBytes)
 }
 
-// dumpSlice handles formatting of arrays and slices.  Byte (uint8 under
-// reflection) arrays and slices are dumped in hexdump -C fashion.
-func (d *dumpState) dumpSlice(v reflect.Value) {
-	// Determine whether this type should be hex dumped or not.  Also,
-	// for types which should be hexdumped, try to use the underlying data
-	// first, then fall back to trying to convert them to a uint8 slice.
-	var buf []uint8
-	doConvert := false
-	doHexDump := false
+// byteSliceOf returns the contents of the array or slice v as a uint8 slice
+// when it holds bytes or cgo char types, which are displayed in hexdump -C
+// fashion.  It tries to use the underlying data first, then falls back to
+// converting and copying the elements.
+func byteSliceOf(cs *ConfigState, v reflect.Value) (buf []uint8, ok bool) {
 	numEntries := v.Len()
-	if numEntries > 0 {
-		vt := v.Index(0).Type()
-		vts := vt.String()
-		switch {
-		// C types that need to be converted.
-		case cCharRE.MatchString(vts):
-			fallthrough
-		case cUnsignedCharRE.MatchString(vts):
-			fallthrough
-		case cUint8tCharRE.MatchString(vts):
-			doConvert = true
+	if numEntries == 0 {
+		return nil, false
+	}
 
-		// Try to use existing uint8 slices and fall back to converting
-		// and copying if that fails.
-		case vt.Kind() == reflect.Uint8:
-			if vt.Implements(fmtStringerType) {
-				doConvert = d.cs.DisableMethods
-			} else {
-				// We need an addressable interface to convert the type
-				// to a byte slice.  However, the reflect package won't
-				// give us an interface on certain things like
-				// unexported struct fields in order to enforce
-				// visibility rules.  We use unsafe, when available, to
-				// bypass these restrictions since this package does not
-				// mutate the values.
-				vs := v
-				if !vs.CanInterface() || !vs.CanAddr() {
-					vs = unsafeReflectValue(vs)
-				}
-				if !UnsafeDisabled {
-					vs = vs.Slice(0, numEntries)
-
-					// Use the existing uint8 slice if it can be
-					// type asserted.
-					iface := vs.Interface()
-					if slice, ok := iface.([]uint8); ok {
-						buf = slice
-						doHexDump = true
-						break
-					}
+	doConvert := false
+	vt := v.Index(0).Type()
+	vts := vt.String()
+	switch {
+	// C types that need to be converted.
+	case cCharRE.MatchString(vts):
+		fallthrough
+	case cUnsignedCharRE.MatchString(vts):
+		fallthrough
+	case cUint8tCharRE.MatchString(vts):
+		doConvert = true
+
+	// Try to use existing uint8 slices and fall back to converting
+	// and copying if that fails.
+	case vt.Kind() == reflect.Uint8:
+		if vt.Implements(fmtStringerType) {
+			doConvert = cs.DisableMethods
+		} else {
+			// We need an addressable interface to convert the type
+			// to a byte slice.  However, the reflect package won't
+			// give us an interface on certain things like
+			// unexported struct fields in order to enforce
+			// visibility rules.  We use unsafe, when available, to
+			// bypass these restrictions since this package does not
+			// mutate the values.
+			vs := v
+			if !vs.CanInterface() || !vs.CanAddr() {
+				vs = unsafeReflectValue(vs)
+			}
+			if !UnsafeDisabled {
+				vs = vs.Slice(0, numEntries)
+
+				// Use the existing uint8 slice if it can be
+				// type asserted.
+				iface := vs.Interface()
+				if slice, ok := iface.([]uint8); ok {
+					return slice, true
 				}
-
-				// The underlying data needs to be converted if it can't
-				// be type asserted to a uint8 slice.
-				doConvert = true
 			}
+
+			// The underlying data needs to be converted if it can't
+			// be type asserted to a uint8 slice.
+			doConvert = true
 		}
+	}
 
-		// Copy and convert the underlying type if needed.
-		if doConvert && vt.ConvertibleTo(uint8Type) {
-			// Convert and copy each element into a uint8 byte
-			// slice.
-			buf = make([]uint8, numEntries)
-			for i := 0; i < numEntries; i++ {
-				vv := v.Index(i)
-				buf[i] = uint8(vv.Convert(uint8Type).Uint())
-			}
-			doHexDump = true
+	// Copy and convert the underlying type if needed.
+	if doConvert && vt.ConvertibleTo(uint8Type) {
+		// Convert and copy each element into a uint8 byte
+		// slice.
+		buf = make([]uint8, numEntries)
+		for i := 0; i < numEntries; i++ {
+			vv := v.Index(i)
+			buf[i] = uint8(vv.Convert(uint8Type).Uint())
 		}
+		return buf, true
 	}
+	return nil, false
+}
 
+// dumpSlice handles formatting of arrays and slices.  Byte (uint8 under
+// reflection) arrays and slices are dumped in hexdump -C fashion.  The
+// elements of other arrays and slices are written by stepSlice.
+func (d *dumpState) dumpSlice(v reflect.Value) {
 	// Hexdump the entire slice as needed.
-	if doHexDump {
-		var indent string
-		key := indentCacheKey{d.cs.Indent, d.depth}
-		if cv, ok := indentCache.Load(key); ok {
-			indent = cv.(string)
-		} else {
-			indent = strings.Repeat(d.cs.Indent, d.depth)
-			indentCache.Store(key, indent)
+	if buf, ok := byteSliceOf(d.cs, v); ok {
+		indent := d.indentString()
+		head, tail := d.field.span(d.cs, len(buf))
+		if n := d.budget.limit(d.out.n, 4); n >= 0 && head+tail > n {
+			head, tail = n, 0
 		}
-		hexDump(d.w, buf, indent)
+		d.setErr(hexDump(d.w, buf[:head], indent, 0, &d.cs.HexDump))
+		if head+tail < len(buf) {
+			io.WriteString(d.w, indent)
+			printElided(d.w, len(buf)-head-tail)
+			d.w.Write(newlineBytes)
+			d.setErr(hexDump(d.w, buf[len(buf)-tail:], indent, uint(len(buf)-tail), &d.cs.HexDump))
+		}
+		d.closeBrace()
 		return
 	}
+	d.stack.push(reflect.Array, v)
+}
 
-	// Recursively call dump for each item.
-	for i := 0; i < numEntries; i++ {
-		d.dump(d.unpackValue(v.Index(i)))
-		if i < (numEntries - 1) {
+// stepSlice writes the separator following the element of the array or
+// slice of the frame fr which was written last, if any, and starts the next
+// element or ends the array or slice.
+func (d *dumpState) stepSlice(fr *walkFrame) {
+	numEntries := fr.v.Len()
+	i := fr.next
+	if i < numEntries && d.budget.spent(d.out.n+sepLen(fr.entry, commaNewlineBytes)) {
+		d.dumpTruncated(numEntries-i, fr.entry)
+		fr.entry = false
+		i = numEntries
+	}
+	if fr.entry {
+		if i < numEntries {
 			d.w.Write(commaNewlineBytes)
 		} else {
 			d.w.Write(newlineBytes)
 		}
+		fr.entry = false
+	}
+	head, tail := d.field.span(d.cs, numEntries)
+	if i == head && head+tail < numEntries {
+		d.dumpElided(numEntries-head-tail, tail > 0)
+		i = numEntries - tail
+	}
+	if i >= numEntries {
+		d.stack.pop()
+		d.closeBrace()
+		return
+	}
+	fr.next = i + 1
+	fr.entry = true
+	d.dumpValue(d.unpackValue(fr.v.Index(i)))
+}
+
+// setErr records err as the error of the dump unless there already is one.
+func (d *dumpState) setErr(err error) {
+	if d.out.err == nil {
+		d.out.err = err
+	}
+}
+
+// dumpTruncated writes the line of the marker of n entries skipped after the
+// output budget was used up, preceded by the separator of the entry written
+// last when sep is set.  Only the end of the line of that entry is written
+// once the context of the dump is canceled, which is marked at its end.
+func (d *dumpState) dumpTruncated(n int, sep bool) {
+	if d.budget.ctxErr != nil {
+		if sep {
+			d.w.Write(newlineBytes)
+		}
+		return
+	}
+	if sep {
+		d.w.Write(commaNewlineBytes)
+	}
+	d.indent()
+	printTruncated(d.w, n, d.theme)
+	d.w.Write(newlineBytes)
+}
+
+// closeBrace ends an array, slice, map or struct on a new line.
+func (d *dumpState) closeBrace() {
+	d.depth--
+	d.indent()
+	d.w.Write(closeBraceBytes)
+}
+
+// dumpElided writes the line of the marker of n elided entries, which is
+// followed by a comma when more entries are printed after it.
+func (d *dumpState) dumpElided(n int, more bool) {
+	d.indent()
+	printElided(d.w, n)
+	if more {
+		d.w.Write(commaNewlineBytes)
+	} else {
+		d.w.Write(newlineBytes)
 	}
 }
 
-// dump is the main workhorse for dumping a value.  It uses the passed reflect
-// value to figure out what kind of object we are dealing with and formats it
-// appropriately.  It is a recursive function, however circular data structures
-// are detected and handled properly.
+// dumpBytes handles formatting of byte arrays and slices in the inline mode m,
+// which is BytesString, BytesBase64 or BytesHex, as requested by the ByteMode
+// option or the bytes directive of a field tag.
+func (d *dumpState) dumpBytes(buf []byte, m ByteMode) {
+	shown := d.field.truncateBytes(d.cs, buf, m)
+	if n := d.budget.limit(d.out.n, 2); n >= 0 && len(shown) > n {
+		shown = shown[:n]
+	}
+	b := bufferGet()
+	defer bufferPut(b)
+	b.SetBytes(appendBytes(b.Bytes(), shown, m))
+	d.theme.writeToken(d.w, tokenString, b.Bytes())
+	if len(shown) < len(buf) {
+		printElided(d.w, len(buf)-len(shown))
+	}
+}
+
+// writeType writes the type t in parentheses.
+func (d *dumpState) writeType(t reflect.Type) {
+	d.theme.start(d.w, tokenType)
+	d.w.Write(openParenBytes)
+	io.WriteString(d.w, t.String())
+	d.w.Write(closeParenBytes)
+	d.theme.end(d.w, tokenType)
+}
+
+// printNested dumps v one level deeper than the current value at the current
+// position of the output for formatter funcs.  The value is written entirely
+// before printNested returns, on top of the frames of the current traversal.
+func (d *dumpState) printNested(v reflect.Value) {
+	d.depth++
+	d.ignoreNextIndent = true
+	d.dump(d.unpackValue(v))
+	d.depth--
+}
+
+// circular returns whether printNested would print the pointer v as a
+// circular reference.
+func (d *dumpState) circular(v reflect.Value) bool {
+	return d.ci.circular(v, d.depth+1)
+}
+
+// dumpRedacted writes the type of v followed by a marker instead of its value
+// for redacted struct fields and map entries.
+func (d *dumpState) dumpRedacted(v reflect.Value) {
+	d.writeType(v.Type())
+	d.w.Write(spaceBytes)
+	printRedacted(d.w, v, d.theme)
+}
+
+// dump is the main workhorse for dumping a value.  It writes v with
+// dumpValue and then steps the frames it pushed until v and everything it
+// contains is written or writing to the output failed.  The traversal isn't
+// recursive, so the depth of v is only limited by the heap, and circular data
+// structures are detected and handled properly.
 func (d *dumpState) dump(v reflect.Value) {
+	base := len(d.stack)
+	d.dumpValue(v)
+	for len(d.stack) > base {
+		if d.out.err != nil {
+			d.unwind(base)
+			return
+		}
+		fr := d.stack.top()
+		switch fr.kind {
+		case reflect.Ptr:
+			d.stack.pop()
+			d.w.Write(closeParenBytes)
+		case reflect.Array:
+			d.stepSlice(fr)
+		case reflect.Map:
+			d.stepMap(fr)
+		case reflect.Struct:
+			d.stepStruct(fr)
+		}
+	}
+}
+
+// unwind removes the frames above base without writing the rest of their
+// values, restoring the depth and field state they changed.
+func (d *dumpState) unwind(base int) {
+	for len(d.stack) > base {
+		fr := d.stack.top()
+		switch fr.kind {
+		case reflect.Struct:
+			d.field = fr.field
+			d.depth--
+		case reflect.Array, reflect.Map:
+			d.depth--
+		}
+		d.stack.pop()
+	}
+}
+
+// dumpValue uses the passed reflect value to figure out what kind of object
+// we are dealing with and formats it appropriately.  Pointers, arrays,
+// slices, maps and structs push a frame for the values they contain, which
+// are written by the following steps of dump.
+func (d *dumpState) dumpValue(v reflect.Value) {
 	// Handle invalid reflect values immediately.
 	kind := v.Kind()
 	if kind == reflect.Invalid {
-		d.w.Write(invalidAngleBytes)
+		d.theme.writeToken(d.w, tokenNil, invalidAngleBytes)
+		return
+	}
+	d.budget.nodes++
+
+	// Call the formatter func registered for the type of the value.
+	if fn := d.cs.formatterOf(v); fn != nil {
+		if !d.ignoreNextType {
+			d.indent()
+			d.writeType(v.Type())
+			d.w.Write(spaceBytes)
+		}
+		d.ignoreNextType = false
+		fn(d.w, v, &ValueState{
+			Depth:  d.depth,
+			Prefix: d.indentString(),
+			Indent: d.cs.Indent,
+			p:      d,
+		})
 		return
 	}
 
@@ -252,9 +491,7 @@ func (d *dumpState) dump(v reflect.Value) {
 	// Print type information unless already handled elsewhere.
 	if !d.ignoreNextType {
 		d.indent()
-		d.w.Write(openParenBytes)
-		io.WriteString(d.w, v.Type().String())
-		d.w.Write(closeParenBytes)
+		d.writeType(v.Type())
 		d.w.Write(spaceBytes)
 	}
 	d.ignoreNextType = false
@@ -269,6 +506,7 @@ func (d *dumpState) dump(v reflect.Value) {
 		valueLen = v.Len()
 	}
 	if valueLen != 0 || !d.cs.DisableCapacities && valueCap != 0 {
+		d.theme.start(d.w, tokenLength)
 		d.w.Write(openParenBytes)
 		if valueLen != 0 {
 			d.w.Write(lenEqualsBytes)
@@ -282,88 +520,183 @@ func (d *dumpState) dump(v reflect.Value) {
 			printInt(d.w, int64(valueCap), 10)
 		}
 		d.w.Write(closeParenBytes)
+		d.theme.end(d.w, tokenLength)
 		d.w.Write(spaceBytes)
 	}
 
-	printValue(d.w, d, v, kind, d.cs)
+	// Print integers and strings in hex as requested by a field tag.
+	if d.field.hex && printHexValue(d.w, v, kind, d.theme) {
+		return
+	}
+
+	// Write values which fit on the rest of the line inline.
+	if d.width > 0 && d.inline(v, kind) {
+		return
+	}
+
+	printValue(d.w, d, v, kind, d.cs, d.theme)
 }
 
 func (d *dumpState) printArray(v reflect.Value) {
+	if m := d.field.byteMode(d.cs); m != BytesDefault && m != BytesHexdump {
+		if buf, ok := byteSliceOf(d.cs, v); ok {
+			if m = m.resolve(buf); m != BytesHexdump {
+				d.dumpBytes(buf, m)
+				return
+			}
+		}
+	}
+
+	if d.openBrace() {
+		d.dumpSlice(v)
+	}
+}
+
+// openBrace starts an array, slice, map or struct and returns whether its
+// entries are within the depth limits.  The max depth marker is written and
+// the value ended when they are not.
+func (d *dumpState) openBrace() bool {
 	d.w.Write(openBraceNewlineBytes)
 	d.depth++
-	if (d.cs.MaxDepth != 0) && (d.depth > d.cs.MaxDepth) {
+	if d.field.maxDepthReached(d.cs, d.depth) {
 		d.indent()
-		d.w.Write(maxNewlineBytes)
-	} else {
-		d.dumpSlice(v)
+		d.theme.writeToken(d.w, tokenMaxDepth, maxBytes)
+		d.w.Write(newlineBytes)
+		d.closeBrace()
+		return false
 	}
-	d.depth--
-	d.indent()
-	d.w.Write(closeBraceBytes)
+	return true
 }
 
 func (d *dumpState) printString(v reflect.Value) {
 	b := bufferGet()
 	defer bufferPut(b)
-	b.SetBytes(strconv.AppendQuote(b.Bytes(), v.String()))
-	d.w.Write(b.Bytes())
+	s := d.field.truncateString(d.cs, v.String())
+	if n := d.budget.limit(d.out.n, 1); n >= 0 {
+		s = runePrefix(s, n)
+	}
+	b.SetBytes(strconv.AppendQuote(b.Bytes(), s))
+	d.theme.writeToken(d.w, tokenString, b.Bytes())
+	if len(s) < v.Len() {
+		printElided(d.w, v.Len()-len(s))
+	}
 }
 
 func (d *dumpState) printMap(v reflect.Value) {
-	d.w.Write(openBraceNewlineBytes)
-	d.depth++
-	if (d.cs.MaxDepth != 0) && (d.depth > d.cs.MaxDepth) {
-		d.indent()
-		d.w.Write(maxNewlineBytes)
-	} else {
-		numEntries := v.Len()
-		keys := v.MapKeys()
-		if d.cs.SortKeys {
-			sortValues(keys, d.cs)
+	if !d.openBrace() {
+		return
+	}
+	fr := d.stack.push(reflect.Map, v)
+	fr.keys = v.MapKeys()
+	if d.cs.SortKeys {
+		sortValues(fr.keys, d.cs)
+	}
+}
+
+// stepMap writes the value of the map entry of the frame fr whose key was
+// written last or the separator following the entry which was written last,
+// if any, and starts the next entry or ends the map.
+func (d *dumpState) stepMap(fr *walkFrame) {
+	numEntries := len(fr.keys)
+	if fr.value {
+		fr.value = false
+		key := fr.keys[fr.next-1]
+		d.w.Write(colonSpaceBytes)
+		if d.cs.redactsKey(key) {
+			d.dumpRedacted(d.unpackValue(fr.v.MapIndex(key)))
+			return
 		}
-		for i, key := range keys {
-			d.dump(d.unpackValue(key))
-			d.w.Write(colonSpaceBytes)
-			d.ignoreNextIndent = true
-			d.dump(d.unpackValue(v.MapIndex(key)))
-			if i < (numEntries - 1) {
-				d.w.Write(commaNewlineBytes)
-			} else {
-				d.w.Write(newlineBytes)
-			}
+		d.ignoreNextIndent = true
+		d.dumpValue(d.unpackValue(fr.v.MapIndex(key)))
+		return
+	}
+	i := fr.next
+	if i < numEntries && d.budget.spent(d.out.n+sepLen(fr.entry, commaNewlineBytes)) {
+		d.dumpTruncated(numEntries-i, fr.entry)
+		fr.entry = false
+		i = numEntries
+	}
+	if fr.entry {
+		if i < numEntries {
+			d.w.Write(commaNewlineBytes)
+		} else {
+			d.w.Write(newlineBytes)
 		}
+		fr.entry = false
 	}
-	d.depth--
-	d.indent()
-	d.w.Write(closeBraceBytes)
+	head, tail := d.field.span(d.cs, numEntries)
+	if i == head && head+tail < numEntries {
+		d.dumpElided(numEntries-head-tail, tail > 0)
+		i = numEntries - tail
+	}
+	if i >= numEntries {
+		d.stack.pop()
+		d.closeBrace()
+		return
+	}
+	fr.next = i + 1
+	fr.entry = true
+	fr.value = true
+	d.dumpValue(d.unpackValue(fr.keys[i]))
 }
 
 func (d *dumpState) printStruct(v reflect.Value) {
-	d.w.Write(openBraceNewlineBytes)
-	d.depth++
-	if (d.cs.MaxDepth != 0) && (d.depth > d.cs.MaxDepth) {
+	if !d.openBrace() {
+		return
+	}
+	fr := d.stack.push(reflect.Struct, v)
+	fr.field = d.field
+}
+
+// stepStruct starts the next field of the struct of the frame fr or ends the
+// struct once all of its fields are written.
+func (d *dumpState) stepStruct(fr *walkFrame) {
+	vt := fr.v.Type()
+	opts := fieldOptionsOf(vt)
+	for fr.next < len(opts) {
+		i := fr.next
+		fr.next++
+		o := opts[i]
+		vf := fr.v.Field(i)
+		if o.skip || o.omitEmpty && vf.IsZero() {
+			continue
+		}
+		if d.budget.spent(d.out.n + sepLen(fr.printed > 0, commaNewlineBytes)) {
+			d.dumpTruncated(1+fieldsLeft(fr.v, opts, fr.next), fr.printed > 0)
+			d.field = fr.field
+			d.stack.pop()
+			d.closeBrace()
+			return
+		}
+		if fr.printed > 0 {
+			d.w.Write(commaNewlineBytes)
+		}
+		fr.printed++
 		d.indent()
-		d.w.Write(maxNewlineBytes)
-	} else {
-		vt := v.Type()
-		numFields := v.NumField()
-		for i := 0; i < numFields; i++ {
-			d.indent()
-			vtf := vt.Field(i)
-			io.WriteString(d.w, vtf.Name)
-			d.w.Write(colonSpaceBytes)
-			d.ignoreNextIndent = true
-			d.dump(d.unpackValue(v.Field(i)))
-			if i < (numFields - 1) {
-				d.w.Write(commaNewlineBytes)
-			} else {
-				d.w.Write(newlineBytes)
-			}
+		vtf := vt.Field(i)
+		d.theme.start(d.w, tokenField)
+		io.WriteString(d.w, vtf.Name)
+		d.theme.end(d.w, tokenField)
+		d.w.Write(colonSpaceBytes)
+		if d.cs.redactsField(o, vtf.Name) {
+			d.dumpRedacted(d.unpackValue(vf))
+			continue
 		}
+		d.ignoreNextIndent = true
+		d.field = fr.field.enter(o, d.depth)
+		d.dumpValue(d.unpackValue(vf))
+		return
 	}
-	d.depth--
-	d.indent()
-	d.w.Write(closeBraceBytes)
+	d.field = fr.field
+	if fr.printed > 0 {
+		d.w.Write(newlineBytes)
+	}
+	d.stack.pop()
+	d.closeBrace()
+}
+
+func (d *dumpState) methodStats() *methodStats {
+	return &d.stats
 }
 
 func (d *dumpState) defaultFormat() string {
@@ -371,31 +704,131 @@ func (d *dumpState) defaultFormat() string {
 }
 
 func (d *dumpState) Reset(w io.Writer, cs *ConfigState) {
-	*d = dumpState{w: w, ci: d.ci, cs: cs}
+	d.stack.reset()
+	d.fstack.reset()
+	*d = dumpState{w: w, ci: d.ci, cs: cs, theme: cs.theme(w), labels: d.labels, ids: d.ids,
+		stack: d.stack, fstack: d.fstack}
+	d.labels.labels = nil
+	d.ids.ids = nil
+	d.out.w = w
+	d.w = &d.out
+	d.stats.path = d.valuePath
+	d.budget.reset(cs, nil)
+	if d.width = cs.lineWidth(w); d.width > 0 {
+		d.col.w = &d.out
+		d.w = &d.col
+	}
+}
+
+// DumpReport summarizes a dump written by FdumpReport.
+type DumpReport struct {
+	// Bytes is the number of bytes written.
+	Bytes int64
+
+	// MethodsInvoked is the number of error and Stringer interface methods
+	// called to display values, including the ones which panicked or timed
+	// out.
+	MethodsInvoked int
+
+	// MethodsPanicked is the number of method calls which panicked.
+	MethodsPanicked int
+
+	// MethodsTimedOut is the number of method calls which didn't return
+	// within the MethodTimeout option.
+	MethodsTimedOut int
+
+	// Panics describes the panics displayed in the output in the order
+	// they occurred.  Values written inline are formatted once more when
+	// they don't fit on the line, so MethodsPanicked can exceed their
+	// number.
+	Panics []MethodPanic
+}
+
+// MethodPanic describes the panic of an error or Stringer interface method
+// recovered while dumping, which is displayed as (PANIC=Value).
+type MethodPanic struct {
+	// Arg is the index of the argument the value belongs to and Path the
+	// access path of the value inside of it, such as Items[2].Name.
+	Arg  int
+	Path string
+
+	// Type is the type of the value the method was called for.
+	Type reflect.Type
+
+	// Value is the value passed to panic and Stack the stack trace of the
+	// goroutine the method panicked on.
+	Value interface{}
+	Stack []byte
+}
+
+// valuePath returns the access path of the value being written inside the
+// current argument, including the values being written inline.
+func (d *dumpState) valuePath() string {
+	path := d.stack.path(d.cs, "")
+	if d.inl != nil {
+		path = d.inl.stack.path(d.cs, path)
+	}
+	if path == "" {
+		return rootPath
+	}
+	return path
 }
 
 // fdump is a helper function to consolidate the logic from the various public
-// methods which take varying writers and config states.
-func fdump(cs *ConfigState, w io.Writer, a ...interface{}) {
+// methods which take varying contexts, writers and config states.  It stops
+// at the first failed write or once ctx is done and returns the report of the
+// dump along with the error of the failed write or of ctx.
+func fdump(ctx context.Context, cs *ConfigState, w io.Writer, a ...interface{}) (r DumpReport, err error) {
 	d := dumpStateGet(w, cs)
 	defer dumpStatePut(d)
+	d.budget.ctx = ctx
+	if cs.ReferenceLabels {
+		d.labels.reset(a...)
+	}
+	if cs.SymbolicPointers {
+		d.ids.reset()
+	}
 
-	for _, arg := range a {
+	for i, arg := range a {
+		if d.out.err != nil {
+			break
+		}
+		if d.budget.spent(d.out.n) {
+			if d.budget.ctxErr == nil {
+				printTruncated(d.w, len(a)-i, d.theme)
+				d.w.Write(newlineBytes)
+			}
+			break
+		}
 		if arg == nil {
-			w.Write(interfaceBytes)
-			w.Write(spaceBytes)
-			w.Write(nilAngleBytes)
-			w.Write(newlineBytes)
+			d.theme.writeToken(d.w, tokenType, interfaceBytes)
+			d.w.Write(spaceBytes)
+			d.theme.writeToken(d.w, tokenNil, nilAngleBytes)
+			d.w.Write(newlineBytes)
 			continue
 		}
 
-		for k := range d.ci.pointers {
-			delete(d.ci.pointers, k)
-		}
+		d.ci.resetPointers()
 
+		d.stats.arg = i
 		d.dump(reflect.ValueOf(arg))
 		d.w.Write(newlineBytes)
 	}
+	if d.budget.ctxErr != nil {
+		printCanceled(d.w, d.budget.ctxErr, d.theme)
+		d.w.Write(newlineBytes)
+	}
+	r = DumpReport{
+		Bytes:           d.out.n,
+		MethodsInvoked:  d.stats.invoked,
+		MethodsPanicked: d.stats.panicked,
+		MethodsTimedOut: d.stats.timedOut,
+		Panics:          d.stats.panics,
+	}
+	if d.out.err != nil {
+		return r, d.out.err
+	}
+	return r, d.budget.ctxErr
 }
 
 var dumpStatePool = sync.Pool{New: func() interface{} {
@@ -412,5 +845,10 @@ func dumpStateGet(w io.Writer, cs *ConfigState) *dumpState {
 func dumpStatePut(d *dumpState) {
 	cycleInfoPut(d.ci)
 	d.ci = nil
+	d.labels.census = pointerCensus{}
+	d.labels.labels = nil
+	d.ids.ids = nil
+	d.stack.reset()
+	d.fstack.reset()
 	dumpStatePool.Put(d)
 }
@@ -64,10 +64,15 @@ package spew_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"reflect"
+	"runtime/debug"
 	"strings"
 	"testing"
+	"time"
 	"unsafe"
 
 	"github.com/spewerspew/spew"
@@ -743,6 +748,40 @@ func addStructDumpTests() {
 	addDumpTest(nv4, "(*"+v4t+")(<nil>)\n")
 }
 
+func addTagDumpTests() {
+	v := tagged{
+		Skipped:  1,
+		Password: "hunter2",
+		Flags:    0xbeef,
+		Long:     "abcdef",
+		Many:     []int{1, 2, 3, 4},
+		Deep:     [][]int{{1}},
+		Raw:      []byte("raw\n"),
+		Plain:    5,
+	}
+	vt := "spew_test.tagged"
+	vs := "{\n Password: (string) <redacted len=7>,\n Flags: (uint16) 0xbeef,\n" +
+		" Long: (string) (len=6) \"abc\"...(3 more),\n" +
+		" Many: ([]int) (len=4 cap=4) {\n  (int) 1,\n  (int) 2,\n  ...(2 more)\n },\n" +
+		" Deep: ([][]int) (len=1 cap=1) {\n  ([]int) (len=1 cap=1) {\n   <max depth reached>\n  }\n },\n" +
+		" Raw: ([]uint8) (len=4 cap=4) \"raw\\n\",\n" +
+		" Plain: (int) 5\n}"
+	addDumpTest(v, "("+vt+") "+vs+"\n")
+
+	// Directives don't apply to the fields of nested structs.
+	type wrapper struct {
+		T tagged `spew:"hex,maxlen=1"`
+		N []int  `spew:"hex,maxlen=1"`
+	}
+	v2 := wrapper{T: tagged{Flags: 1, Long: "ab", Plain: 10}, N: []int{-17, 2}}
+	v2t := "spew_test.wrapper"
+	v2s := "{\n T: (" + vt + ") {\n  Password: (string) <redacted len=0>,\n  Flags: (uint16) 0x1,\n" +
+		"  Long: (string) (len=2) \"ab\",\n  Many: ([]int) <nil>,\n  Deep: ([][]int) <nil>,\n" +
+		"  Raw: ([]uint8) <nil>,\n  Plain: (int) 10\n },\n" +
+		" N: ([]int) (len=2 cap=2) {\n  (int) -0x11,\n  ...(1 more)\n }\n}"
+	addDumpTest(v2, "("+v2t+") "+v2s+"\n")
+}
+
 func addUintptrDumpTests() {
 	// Null pointer.
 	v := uintptr(0)
@@ -973,6 +1012,7 @@ func setupDumpTests() {
 		addInterfaceDumpTests()
 		addMapDumpTests()
 		addStructDumpTests()
+		addTagDumpTests()
 		addUintptrDumpTests()
 		addUnsafePointerDumpTests()
 		addChanDumpTests()
@@ -1017,6 +1057,32 @@ func BenchmarkDump(b *testing.B) {
 	}
 }
 
+func BenchmarkDumpMethods(b *testing.B) {
+	v := struct{ S [8]stringer }{}
+	for i := range v.S {
+		v.S[i] = stringer(strings.Repeat("s", i+1))
+	}
+	b.Run("Fdump", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			spew.Fdump(io.Discard, v)
+		}
+	})
+	b.Run("Fprint", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			spew.Fprint(io.Discard, v)
+		}
+	})
+	b.Run("DetectMethodReentry", func(b *testing.B) {
+		cfg := spew.ConfigState{Indent: " ", DetectMethodReentry: true}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			cfg.Fdump(io.Discard, v)
+		}
+	})
+}
+
 func TestDumpSortedKeys(t *testing.T) {
 	cfg := spew.ConfigState{SortKeys: true}
 	s := cfg.Sdump(map[int]string{1: "1", 3: "3", 2: "2"})
@@ -1066,3 +1132,727 @@ func TestDumpSortedKeys(t *testing.T) {
 	}
 
 }
+
+func TestDumpMaxElements(t *testing.T) {
+	cfg := spew.ConfigState{Indent: " ", SortKeys: true, MaxElements: 2, MaxStringLen: 4}
+	s := cfg.Sdump([]int{1, 2, 3, 4, 5})
+	expected := "([]int) (len=5 cap=5) {\n (int) 1,\n (int) 2,\n ...(3 more)\n}\n"
+	if s != expected {
+		t.Errorf("Max elements mismatch:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sdump(map[string]int{"a": 1, "b": 2, "c": 3})
+	expected = "(map[string]int) (len=3) {\n" +
+		" (string) (len=1) \"a\": (int) 1,\n" +
+		" (string) (len=1) \"b\": (int) 2,\n" +
+		" ...(1 more)\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Max elements mismatch:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sdump("hééllo")
+	expected = "(string) (len=8) \"hé\"...(5 more)\n"
+	if s != expected {
+		t.Errorf("Max string length mismatch:\n  %v %v", s, expected)
+	}
+
+	cfg.TailElements = 1
+	s = cfg.Sdump([]int{1, 2, 3, 4, 5})
+	expected = "([]int) (len=5 cap=5) {\n (int) 1,\n (int) 2,\n ...(2 more),\n (int) 5\n}\n"
+	if s != expected {
+		t.Errorf("Tail elements mismatch:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sdump([]int{1, 2, 3})
+	expected = "([]int) (len=3 cap=3) {\n (int) 1,\n (int) 2,\n (int) 3\n}\n"
+	if s != expected {
+		t.Errorf("Tail elements mismatch:\n  %v %v", s, expected)
+	}
+
+	cfg.MaxElements = 18
+	s = cfg.Sdump([]byte("0123456789abcdefghijklmnopqrstuvwxyz"))
+	expected = "([]uint8) (len=36 cap=36) {\n" +
+		" 00000000  30 31 32 33 34 35 36 37  38 39 61 62 63 64 65 66  |0123456789abcdef|\n" +
+		" 00000010  67 68                                             |gh|\n" +
+		" ...(17 more)\n" +
+		" 00000023  7a                                                |z|\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Tail elements mismatch:\n  %v %v", s, expected)
+	}
+}
+
+func TestDumpHexDumpOptions(t *testing.T) {
+	cfg := spew.ConfigState{Indent: " ", HexDump: spew.HexDumpOptions{
+		BytesPerLine: 6,
+		GroupSize:    2,
+		Uppercase:    true,
+		OffsetWidth:  4,
+		BaseOffset:   0xfffffffe,
+		Text:         spew.HexDumpUTF8,
+	}}
+	s := cfg.Sdump([]byte("h\xffé€\x00z"))
+	expected := "([]uint8) (len=9 cap=9) {\n" +
+		" 0FFFFFFFE  68 FF  C3 A9  E2 82  |h.é € |\n" +
+		" 100000004  AC 00  7A            | .z|\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Hexdump options mismatch:\n  %v %v", s, expected)
+	}
+
+	cfg.HexDump = spew.HexDumpOptions{GroupSize: -1, Text: spew.HexDumpNoText}
+	s = cfg.Sdump([]byte("0123456789abcdefghij"))
+	expected = "([]uint8) (len=20 cap=20) {\n" +
+		" 00000000  30 31 32 33 34 35 36 37 38 39 61 62 63 64 65 66\n" +
+		" 00000010  67 68 69 6a\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Hexdump options mismatch:\n  %v %v", s, expected)
+	}
+}
+
+func TestDumpByteMode(t *testing.T) {
+	tests := []struct {
+		mode     spew.ByteMode
+		in       []byte
+		expected string
+	}{
+		{spew.BytesString, []byte("hi\n"), "([]uint8) (len=3 cap=3) \"hi\\n\"\n"},
+		{spew.BytesString, []byte{0xff}, "([]uint8) (len=1 cap=1) {\n" +
+			" 00000000  ff                                                |.|\n}\n"},
+		{spew.BytesBase64, []byte{0xff, 0}, "([]uint8) (len=2 cap=2) /wA=\n"},
+		{spew.BytesHex, []byte{0xff, 0}, "([]uint8) (len=2 cap=2) ff00\n"},
+		{spew.BytesAuto, []byte("héllo"), "([]uint8) (len=6 cap=6) \"héllo\"\n"},
+		{spew.BytesAuto, []byte{0xff, 0}, "([]uint8) (len=2 cap=2) ff00\n"},
+		{spew.BytesAuto, make([]byte, 17), "([]uint8) (len=17 cap=17) 0000000000000000000000000000000000\n"},
+		{spew.BytesAuto, make([]byte, 33), "([]uint8) (len=33 cap=33) {\n" +
+			" 00000000  00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00  |................|\n" +
+			" 00000010  00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00  |................|\n" +
+			" 00000020  00                                                |.|\n}\n"},
+	}
+	for i, test := range tests {
+		cfg := spew.ConfigState{Indent: " ", ByteMode: test.mode}
+		if s := cfg.Sdump(test.in); s != test.expected {
+			t.Errorf("Byte mode mismatch #%d:\n  %v %v", i, s, test.expected)
+		}
+	}
+
+	type tagged struct {
+		A []byte  `spew:"bytes=base64"`
+		B [2]byte `spew:"bytes=hex"`
+		C []byte  `spew:"bytes=hexdump"`
+	}
+	cfg := spew.ConfigState{Indent: " ", ByteMode: spew.BytesString, MaxElements: 1}
+	s := cfg.Sdump(tagged{[]byte("hi"), [2]byte{1, 2}, []byte("x")})
+	expected := "(spew_test.tagged) {\n" +
+		" A: ([]uint8) (len=2 cap=2) aA==...(1 more),\n" +
+		" B: ([2]uint8) (len=2 cap=2) 01...(1 more),\n" +
+		" C: ([]uint8) (len=1 cap=1) {\n" +
+		"  00000000  78                                                |x|\n" +
+		" }\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Byte mode tag mismatch:\n  %v %v", s, expected)
+	}
+}
+
+func TestDumpLineWidth(t *testing.T) {
+	type point struct{ X, Y int }
+	type shape struct {
+		Name   string
+		Points []point
+		Tags   map[string]int
+		Next   *shape
+		Label  stringer
+	}
+	v := &shape{Name: "tri", Points: []point{{1, 2}, {3, 4}, {5, 6}}, Tags: map[string]int{"a": 1}, Label: "x"}
+	v.Next = v
+
+	cfg := spew.ConfigState{Indent: " ", LineWidth: 60, DisablePointerAddresses: true}
+	s := cfg.Sdump(v)
+	expected := "(*spew_test.shape)({\n" +
+		" Name: (string) (len=3) \"tri\",\n" +
+		" Points: ([]spew_test.point) (len=3 cap=3) {\n" +
+		"  (spew_test.point) {X:1 Y:2},\n" +
+		"  (spew_test.point) {X:3 Y:4},\n" +
+		"  (spew_test.point) {X:5 Y:6}\n" +
+		" },\n" +
+		" Tags: (map[string]int) (len=1) map[a:1],\n" +
+		" Next: (*spew_test.shape)(<already shown>),\n" +
+		" Label: (spew_test.stringer) (len=1) stringer x\n" +
+		"})\n"
+	if s != expected {
+		t.Errorf("Line width mismatch:\n  %v %v", s, expected)
+	}
+
+	t.Setenv("COLUMNS", "")
+	cfg.LineWidth = spew.LineWidthTerminal
+	s = cfg.Sdump(v.Points)
+	expected = "([]spew_test.point) (len=3 cap=3) [{X:1 Y:2} {X:3 Y:4} {X:5 Y:6}]\n"
+	if s != expected {
+		t.Errorf("Terminal line width mismatch:\n  %v %v", s, expected)
+	}
+
+	t.Setenv("COLUMNS", "40")
+	s = cfg.Sdump(v.Points)
+	expected = "([]spew_test.point) (len=3 cap=3) {\n" +
+		" (spew_test.point) {X:1 Y:2},\n" +
+		" (spew_test.point) {X:3 Y:4},\n" +
+		" (spew_test.point) {X:5 Y:6}\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Terminal line width mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// labelNode is used to test reference labels of shared and circular pointers.
+type labelNode struct {
+	V    int
+	L, R *labelNode
+}
+
+func TestDumpReferenceLabels(t *testing.T) {
+	shared := &labelNode{V: 2}
+	root := &labelNode{V: 1, L: shared, R: shared}
+	shared.L = root
+
+	cfg := spew.ConfigState{Indent: " ", DisablePointerAddresses: true, ReferenceLabels: true}
+	s := cfg.Sdump(root, shared)
+	expected := "(*spew_test.labelNode)(&1 {\n" +
+		" V: (int) 1,\n" +
+		" L: (*spew_test.labelNode)(&2 {\n" +
+		"  V: (int) 2,\n" +
+		"  L: (*spew_test.labelNode)(*1),\n" +
+		"  R: (*spew_test.labelNode)(<nil>)\n" +
+		" }),\n" +
+		" R: (*spew_test.labelNode)(*2)\n" +
+		"})\n" +
+		"(*spew_test.labelNode)(*2)\n"
+	if s != expected {
+		t.Errorf("Reference labels mismatch:\n  %v %v", s, expected)
+	}
+
+	// Pointers which are only reached once aren't labeled.
+	s = cfg.Sdump(&labelNode{V: 1})
+	expected = "(*spew_test.labelNode)({\n" +
+		" V: (int) 1,\n" +
+		" L: (*spew_test.labelNode)(<nil>),\n" +
+		" R: (*spew_test.labelNode)(<nil>)\n" +
+		"})\n"
+	if s != expected {
+		t.Errorf("Reference labels mismatch:\n  %v %v", s, expected)
+	}
+
+	// Labels of values which don't fit inline are assigned once.
+	cfg.LineWidth = 60
+	s = cfg.Sdump(root)
+	expected = "(*spew_test.labelNode)(&1 {\n" +
+		" V: (int) 1,\n" +
+		" L: (*spew_test.labelNode)(&2 {V:2 L:<*>*1 R:<nil>}),\n" +
+		" R: (*spew_test.labelNode)(*2)\n" +
+		"})\n"
+	if s != expected {
+		t.Errorf("Reference labels mismatch:\n  %v %v", s, expected)
+	}
+}
+
+func TestDumpSymbolicPointers(t *testing.T) {
+	shared := &labelNode{V: 2}
+	root := &labelNode{V: 1, L: shared, R: shared}
+	pp := &root
+
+	cfg := spew.ConfigState{Indent: " ", SymbolicPointers: true}
+	s := cfg.Sdump(pp, shared)
+	expected := "(**spew_test.labelNode)(p1->p2)({\n" +
+		" V: (int) 1,\n" +
+		" L: (*spew_test.labelNode)(p3)({\n" +
+		"  V: (int) 2,\n" +
+		"  L: (*spew_test.labelNode)(<nil>),\n" +
+		"  R: (*spew_test.labelNode)(<nil>)\n" +
+		" }),\n" +
+		" R: (*spew_test.labelNode)(p3)({\n" +
+		"  V: (int) 2,\n" +
+		"  L: (*spew_test.labelNode)(<nil>),\n" +
+		"  R: (*spew_test.labelNode)(<nil>)\n" +
+		" })\n" +
+		"})\n" +
+		"(*spew_test.labelNode)(p3)({\n" +
+		" V: (int) 2,\n" +
+		" L: (*spew_test.labelNode)(<nil>),\n" +
+		" R: (*spew_test.labelNode)(<nil>)\n" +
+		"})\n"
+	if s != expected {
+		t.Errorf("Symbolic pointers mismatch:\n  %v %v", s, expected)
+	}
+
+	cfg.LineWidth = 80
+	s = cfg.Sdump(root)
+	expected = "(*spew_test.labelNode)(p1)({\n" +
+		" V: (int) 1,\n" +
+		" L: (*spew_test.labelNode)(p2)({V:2 L:<nil> R:<nil>}),\n" +
+		" R: (*spew_test.labelNode)(p2)({V:2 L:<nil> R:<nil>})\n" +
+		"})\n"
+	if s != expected {
+		t.Errorf("Symbolic pointers mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// deepList returns a list of n labelNodes linked through L.
+func deepList(n int) *labelNode {
+	var head *labelNode
+	for i := n; i > 0; i-- {
+		head = &labelNode{V: i, L: head}
+	}
+	return head
+}
+
+func TestDumpDeep(t *testing.T) {
+	// The traversal keeps its own stack, so values nested far deeper than
+	// the goroutine stack allows are dumped.
+	const n = 100000
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+
+	cfg := spew.ConfigState{Indent: "", DisablePointerAddresses: true}
+	s := cfg.Sdump(deepList(n))
+	if got := strings.Count(s, "V: (int) "); got != n {
+		t.Errorf("Deep dump has %d nodes, want %d", got, n)
+	}
+	expected := "(int) 100000,\n" +
+		"L: (*spew_test.labelNode)(<nil>),\n" +
+		"R: (*spew_test.labelNode)(<nil>)\n" +
+		"}),\n" +
+		"R: (*spew_test.labelNode)(<nil>)\n" +
+		"}),\n"
+	if !strings.Contains(s, expected) || !strings.HasSuffix(s, "<nil>)\n})\n") {
+		t.Errorf("Deep dump mismatch:\n  %v %v", s[len(s)-len(expected):], expected)
+	}
+}
+
+// limitWriter accepts up to n bytes and fails the writes past them.  It
+// counts the writes made after the first failed one in late.
+type limitWriter struct {
+	n      int
+	failed bool
+	late   int
+}
+
+var errLimit = errors.New("limit reached")
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if w.failed {
+		w.late++
+	}
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		w.failed = true
+		return n, errLimit
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+// limitStringWriter is a limitWriter which also implements io.StringWriter.
+type limitStringWriter struct{ limitWriter }
+
+func (w *limitStringWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// countedStringer counts the calls of its String method.
+type countedStringer struct{ calls *int }
+
+func (c countedStringer) String() string {
+	*c.calls++
+	return "counted"
+}
+
+func TestDumpWriteError(t *testing.T) {
+	calls := 0
+	v := make([]countedStringer, 1000)
+	for i := range v {
+		v[i] = countedStringer{&calls}
+	}
+
+	w := &limitWriter{n: 100}
+	n, err := spew.FdumpErr(w, v, v)
+	if n != 100 || err != errLimit {
+		t.Errorf("FdumpErr = %d, %v, want 100, %v", n, err, errLimit)
+	}
+	if w.late != 0 {
+		t.Errorf("FdumpErr wrote %d times after a failed write", w.late)
+	}
+	if calls > 10 {
+		t.Errorf("FdumpErr called String %d times after a failed write", calls)
+	}
+
+	// Writers which implement io.StringWriter stop at the same byte.
+	sw := &limitStringWriter{limitWriter{n: 100}}
+	n, err = spew.FdumpErr(sw, v, v)
+	if n != 100 || err != errLimit || sw.late != 0 {
+		t.Errorf("FdumpErr string writer = %d, %v, %d late writes, want 100, %v, 0",
+			n, err, sw.late, errLimit)
+	}
+
+	var buf bytes.Buffer
+	n, err = spew.FdumpErr(&buf, v[:2])
+	if err != nil || n != int64(buf.Len()) {
+		t.Errorf("FdumpErr = %d, %v, want %d, <nil>", n, err, buf.Len())
+	}
+	if s := spew.Sdump(v[:2]); buf.String() != s {
+		t.Errorf("FdumpErr mismatch:\n  %v %v", buf.String(), s)
+	}
+
+	// Errors of the hexdump writer surface as well.
+	w = &limitWriter{n: 40}
+	n, err = spew.FdumpErr(w, bytes.Repeat([]byte("x"), 256))
+	if n != 40 || err != errLimit {
+		t.Errorf("FdumpErr hexdump = %d, %v, want 40, %v", n, err, errLimit)
+	}
+}
+
+// budgetValue is used to test the output budget.
+type budgetValue struct {
+	A []int
+	M map[string]int
+	S string
+}
+
+func TestDumpBudget(t *testing.T) {
+	v := budgetValue{A: []int{1, 2, 3, 4, 5}, M: map[string]int{"a": 1, "b": 2}, S: "s"}
+
+	cfg := spew.ConfigState{Indent: " ", MaxNodes: 4, SortKeys: true}
+	s := cfg.Sdump(v, 1)
+	expected := "(spew_test.budgetValue) {\n" +
+		" A: ([]int) (len=5 cap=5) {\n" +
+		"  (int) 1,\n" +
+		"  (int) 2,\n" +
+		"  <truncated: 3 more>\n" +
+		" },\n" +
+		" <truncated: 2 more>\n" +
+		"}\n" +
+		"<truncated: 1 more>\n"
+	if s != expected {
+		t.Errorf("Max nodes mismatch:\n  %v %v", s, expected)
+	}
+
+	cfg = spew.ConfigState{Indent: " ", MaxBytes: 150, SortKeys: true}
+	s = cfg.Sdump(v)
+	expected = "(spew_test.budgetValue) {\n" +
+		" A: ([]int) (len=5 cap=5) {\n" +
+		"  (int) 1,\n" +
+		"  (int) 2,\n" +
+		"  (int) 3,\n" +
+		"  (int) 4,\n" +
+		"  (int) 5\n" +
+		" },\n" +
+		" M: (map[string]int) (len=2) {\n" +
+		"  (string) (len=1) \"a\": (int) 1,\n" +
+		"  <truncated: 1 more>\n" +
+		" },\n" +
+		" <truncated: 1 more>\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Max bytes mismatch:\n  %v %v", s, expected)
+	}
+
+	// Leaf values are cut short to fit.
+	s = cfg.Sdump(strings.Repeat("x", 1000))
+	expected = "(string) (len=1000) \"" + strings.Repeat("x", 130) + "\"...(870 more)\n"
+	if s != expected {
+		t.Errorf("Max bytes string mismatch:\n  %v %v", s, expected)
+	}
+	s = cfg.Sdump(bytes.Repeat([]byte("x"), 1000))
+	if !strings.HasSuffix(s, " ...(971 more)\n}\n") {
+		t.Errorf("Max bytes hexdump mismatch:\n  %v", s)
+	}
+}
+
+// cancelStringer cancels a context on the call of its String method which
+// is number at.
+type cancelStringer struct {
+	calls  *int
+	at     int
+	cancel context.CancelFunc
+}
+
+func (c cancelStringer) String() string {
+	if *c.calls++; *c.calls == c.at {
+		c.cancel()
+	}
+	return "s"
+}
+
+func TestDumpContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cfg := spew.ConfigState{Indent: " "}
+	s, err := cfg.SdumpContext(ctx, []int{1, 2, 3}, 4)
+	expected := "<canceled: context canceled>\n"
+	if s != expected || err != context.Canceled {
+		t.Errorf("Canceled context mismatch:\n  %v %v %v", s, err, expected)
+	}
+
+	// The context is checked periodically during the traversal.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	v := make([]cancelStringer, 1000)
+	for i := range v {
+		v[i] = cancelStringer{&calls, 10, cancel}
+	}
+	var buf bytes.Buffer
+	n, err := cfg.FdumpContext(ctx, &buf, v)
+	if err != context.Canceled || n != int64(buf.Len()) {
+		t.Errorf("FdumpContext = %d, %v, want %d, %v", n, err, buf.Len(), context.Canceled)
+	}
+	if calls >= len(v) || !strings.HasSuffix(buf.String(), " s\n}\n<canceled: context canceled>\n") {
+		t.Errorf("FdumpContext didn't stop after %d calls:\n  %v", calls, buf.String())
+	}
+
+	s, err = cfg.SdumpContext(context.Background(), 1)
+	if s != "(int) 1\n" || err != nil {
+		t.Errorf("SdumpContext = %q, %v, want %q, <nil>", s, err, "(int) 1\n")
+	}
+}
+
+// blockingStringer has a String method which blocks until release is
+// closed.
+type blockingStringer struct {
+	N       int
+	release chan struct{} `spew:"-"`
+}
+
+func (b blockingStringer) String() string {
+	<-b.release
+	return "released"
+}
+
+func TestDumpMethodTimeout(t *testing.T) {
+	blocking := blockingStringer{2, make(chan struct{})}
+	defer close(blocking.release)
+
+	cfg := spew.ConfigState{Indent: " ", MethodTimeout: 10 * time.Millisecond}
+	var buf bytes.Buffer
+	r, err := cfg.FdumpReport(&buf, []interface{}{stringer("s"), panicer(1), blocking})
+	expected := "([]interface {}) (len=3 cap=3) {\n" +
+		" (spew_test.stringer) (len=1) stringer s,\n" +
+		" (spew_test.panicer) (PANIC=test panic)1,\n" +
+		" (spew_test.blockingStringer) <method timed out> {\n" +
+		"  N: (int) 2\n" +
+		" }\n" +
+		"}\n"
+	if buf.String() != expected || err != nil {
+		t.Errorf("Method timeout mismatch:\n  %v %v %v", buf.String(), err, expected)
+	}
+	if r.Bytes != int64(len(expected)) || r.MethodsInvoked != 3 ||
+		r.MethodsPanicked != 1 || r.MethodsTimedOut != 1 {
+		t.Errorf("FdumpReport = %+v, want %d bytes, 3 invoked, 1 panicked, 1 timed out",
+			r, len(expected))
+	}
+
+	// Methods which return in time are displayed as usual.
+	s := cfg.Sdump(stringer("s"))
+	if expected = "(spew_test.stringer) (len=1) stringer s\n"; s != expected {
+		t.Errorf("Method timeout mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// nilStringer has a String method with a pointer receiver which handles nil
+// pointers.
+type nilStringer int
+
+func (n *nilStringer) String() string {
+	if n == nil {
+		return "nil receiver"
+	}
+	return "stringer"
+}
+
+func TestDumpMethodPolicy(t *testing.T) {
+	v := []interface{}{stringer("a"), customError(1)}
+	tests := []struct {
+		allow, deny []spew.MethodFilter
+		want        string
+	}{
+		{nil, nil, " (spew_test.stringer) (len=1) stringer a,\n (spew_test.customError) error: 1\n"},
+		{nil, []spew.MethodFilter{{Type: reflect.TypeOf(stringer(""))}},
+			" (spew_test.stringer) (len=1) \"a\",\n (spew_test.customError) error: 1\n"},
+		{[]spew.MethodFilter{{Type: reflect.TypeOf(new(customError))}}, nil,
+			" (spew_test.stringer) (len=1) \"a\",\n (spew_test.customError) error: 1\n"},
+		{[]spew.MethodFilter{{Package: "fmt"}}, nil,
+			" (spew_test.stringer) (len=1) \"a\",\n (spew_test.customError) 1\n"},
+		{[]spew.MethodFilter{{Package: "github.com/spewerspew/spew_test"}}, nil,
+			" (spew_test.stringer) (len=1) stringer a,\n (spew_test.customError) error: 1\n"},
+	}
+	for i, test := range tests {
+		cfg := spew.ConfigState{Indent: " ", MethodPolicy: &spew.MethodPolicy{Allow: test.allow, Deny: test.deny}}
+		s := cfg.Sdump(v)
+		expected := "([]interface {}) (len=2 cap=2) {\n" + test.want + "}\n"
+		if s != expected {
+			t.Errorf("Method filter #%d mismatch:\n  %v %v", i, s, expected)
+		}
+	}
+
+	var np *nilStringer
+	var vp *stringer
+	policies := []struct {
+		policy spew.NilMethodPolicy
+		want   string
+	}{
+		{spew.NilMethodsSkip, "(*spew_test.nilStringer)(<nil>)\n(*spew_test.stringer)(<nil>)\n"},
+		{spew.NilMethodsAnnotate, "(*spew_test.nilStringer)(<nil receiver: String not called>)\n" +
+			"(*spew_test.stringer)(<nil receiver: String not called>)\n"},
+	}
+	for _, test := range policies {
+		cfg := spew.ConfigState{NilMethods: test.policy}
+		if s := cfg.Sdump(np, vp); s != test.want {
+			t.Errorf("Nil methods %d mismatch:\n  %v %v", test.policy, s, test.want)
+		}
+	}
+
+	// Value receivers panic when they are called for nil pointers.
+	cfg := spew.ConfigState{NilMethods: spew.NilMethodsCall}
+	s := cfg.Sdump(np, vp)
+	if !strings.HasPrefix(s, "(*spew_test.nilStringer)(nil receiver)\n(*spew_test.stringer)((PANIC=value method ") ||
+		!strings.HasSuffix(s, "called using nil *stringer pointer)<nil>)\n") {
+		t.Errorf("Nil methods call mismatch:\n  %v", s)
+	}
+}
+
+// panicField is used to test the report of method panics.
+type panicField struct {
+	Name  string
+	Items []interface{}
+	Map   map[string]panicer
+}
+
+func TestDumpMethodPanics(t *testing.T) {
+	v := panicField{
+		Name:  "n",
+		Items: []interface{}{1, panicer(2)},
+		Map:   map[string]panicer{"k": 3},
+	}
+	var buf bytes.Buffer
+	r, err := spew.FdumpReport(&buf, 1, &v)
+	if err != nil || r.MethodsPanicked != 2 || len(r.Panics) != 2 {
+		t.Fatalf("FdumpReport = %+v, %v, want 2 panics", r, err)
+	}
+	want := []struct {
+		arg  int
+		path string
+	}{{1, "Items[1]"}, {1, `Map["k"]`}}
+	for i, p := range r.Panics {
+		if p.Arg != want[i].arg || p.Path != want[i].path || p.Type != reflect.TypeOf(panicer(0)) ||
+			p.Value != "test panic" {
+			t.Errorf("Panic %d = %d %q %v %v, want %d %q", i, p.Arg, p.Path, p.Type, p.Value,
+				want[i].arg, want[i].path)
+		}
+		if !bytes.Contains(p.Stack, []byte("spew_test.panicer.String")) {
+			t.Errorf("Panic %d stack doesn't contain the method:\n%s", i, p.Stack)
+		}
+	}
+
+	// Values written inline are reported with their path inside the value.
+	cfg := spew.ConfigState{Indent: " ", LineWidth: 80}
+	r, _ = cfg.FdumpReport(&buf, v)
+	if len(r.Panics) != 2 || r.Panics[0].Path != "Items[1]" || r.Panics[1].Path != `Map["k"]` {
+		t.Errorf("Inline panics = %+v", r.Panics)
+	}
+}
+
+// selfStringer has a String method which prints its own value with spew.
+type selfStringer struct{ N int }
+
+func (s selfStringer) String() string {
+	return "self " + spew.Sprint(s)
+}
+
+// selfPtrStringer has a String method with a pointer receiver which prints
+// its own value with spew.
+type selfPtrStringer struct{ N int }
+
+func (s *selfPtrStringer) String() string {
+	return "self " + spew.Sprint(s)
+}
+
+// treeStringer has a String method which prints its children with spew.
+type treeStringer struct {
+	Name string
+	Kids []*treeStringer
+}
+
+func (n *treeStringer) String() string {
+	return "N(" + n.Name + spew.Sprint(n.Kids) + ")"
+}
+
+// gatedStringer has a String method which blocks until release is closed
+// when it's the first one sending on entered.
+type gatedStringer struct{ entered, release chan struct{} }
+
+func (g gatedStringer) String() string {
+	select {
+	case g.entered <- struct{}{}:
+		<-g.release
+	default:
+	}
+	return "gated"
+}
+
+func TestDumpMethodReentry(t *testing.T) {
+	tests := []struct {
+		cfg  *spew.ConfigState
+		in   interface{}
+		want string
+	}{
+		{&spew.ConfigState{DetectMethodReentry: true}, selfStringer{1},
+			"(spew_test.selfStringer) self {1}\n"},
+		{&spew.ConfigState{DetectMethodReentry: true, MethodTimeout: time.Second}, selfStringer{1},
+			"(spew_test.selfStringer) self {1}\n"},
+		{&spew.ConfigState{DetectMethodReentry: true, DisablePointerAddresses: true}, &selfPtrStringer{2},
+			"(*spew_test.selfPtrStringer)(self <*>{2})\n"},
+		{&spew.ConfigState{DetectMethodReentry: true, DisablePointerAddresses: true},
+			&struct{ S selfPtrStringer }{selfPtrStringer{3}},
+			"(*struct { S spew_test.selfPtrStringer })({\nS: (spew_test.selfPtrStringer) self <*>{3}\n})\n"},
+		{&spew.ConfigState{DetectMethodReentry: true, DisablePointerAddresses: true}, &selfStringer{4},
+			"(*spew_test.selfStringer)(self {4})\n"},
+		{&spew.ConfigState{DetectMethodReentry: true}, []selfStringer{{5}},
+			"([]spew_test.selfStringer) (len=1 cap=1) {\n(spew_test.selfStringer) self {5}\n}\n"},
+	}
+	for i, test := range tests {
+		if s := test.cfg.Sdump(test.in); s != test.want {
+			t.Errorf("Reentry #%d mismatch:\n  %q\n  %q", i, s, test.want)
+		}
+	}
+
+	// Values passed by pointer and elements print their own value once as well.
+	cfg := spew.ConfigState{DetectMethodReentry: true}
+	if s, want := cfg.Sprint(&selfStringer{6}), "<*>self {6}"; s != want {
+		t.Errorf("Pointer reentry mismatch:\n  %q\n  %q", s, want)
+	}
+	if s, want := cfg.Sprint([]selfStringer{{7}}), "[self {7}]"; s != want {
+		t.Errorf("Element reentry mismatch:\n  %q\n  %q", s, want)
+	}
+
+	// Methods of other values of the same type are still called.
+	tree := &treeStringer{"a", []*treeStringer{{Name: "b"}, {"c", []*treeStringer{{Name: "d"}}}}}
+	want := "<*>N(a[<*>N(b<nil>) <*>N(c[<*>N(d<nil>)])])"
+	for _, cfg := range []*spew.ConfigState{spew.NewDefaultConfig(), {DetectMethodReentry: true}} {
+		if s := cfg.Sprint(tree); s != want {
+			t.Errorf("Tree mismatch with reentry detection %v:\n  %q\n  %q",
+				cfg.DetectMethodReentry, s, want)
+		}
+	}
+
+	// Methods in progress on other goroutines don't count.
+	g := gatedStringer{make(chan struct{}), make(chan struct{})}
+	done := make(chan string)
+	go func() { done <- cfg.Sprint(g) }()
+	<-g.entered
+	s := cfg.Sprint(g)
+	close(g.release)
+	if s2 := <-done; s != "gated" || s2 != "gated" {
+		t.Errorf("Concurrent methods mismatch: %q %q", s, s2)
+	}
+}
@@ -0,0 +1,389 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"io"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// Some constants in the form of bytes to avoid string overhead when writing
+// flat output.
+var (
+	flatEqualsBytes    = []byte(" = ")
+	flatEmptyBytes     = []byte("{}")
+	flatEmptyMapBytes  = []byte("map[]")
+	flatEmptyListBytes = []byte("[]")
+	flatShownAtBytes   = []byte("<already shown at ")
+)
+
+// flatState contains information about the state of a flat dump operation.
+// It writes every leaf value on its own line prefixed by its access path.
+// The path of a line is written by the first write of the value to the
+// flatState itself, so the values printValue writes become lines while the
+// containers it descends into only write the lines of their entries.
+type flatState struct {
+	w       io.Writer
+	depth   int
+	ci      *cycleInfo
+	cs      *ConfigState
+	methods ConfigState
+	theme   *Theme
+	field   fieldState
+	path    string
+	pending bool
+	open    bool
+	paths   map[uintptr]string
+}
+
+// Write writes p to the line of the current value, starting the line with
+// the path of the value first.
+func (f *flatState) Write(p []byte) (int, error) {
+	if f.pending {
+		f.pending = false
+		f.open = true
+		path := f.path
+		if path == "" {
+			path = rootPath
+		}
+		f.theme.start(f.w, tokenField)
+		io.WriteString(f.w, path)
+		f.theme.end(f.w, tokenField)
+		f.w.Write(flatEqualsBytes)
+	}
+	return f.w.Write(p)
+}
+
+// unpackValue returns values inside of non-nil interfaces when possible.
+func (f *flatState) unpackValue(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// flatPtr handles pointers by indirecting them as necessary.  Pointers add
+// nothing to the path since selectors dereference them implicitly.  Circular
+// pointers are written as a reference to the path of the value they point to.
+func (f *flatState) flatPtr(v reflect.Value) {
+	ve := derefPtr(v, f.depth, f.ci)
+	switch {
+	case f.ci.nilFound:
+		if !handleNilMethods(&f.methods, f, ve, nil, f.theme) {
+			f.theme.writeToken(f, tokenNil, nilAngleBytes)
+		}
+
+	case f.ci.cycleFound:
+		path := f.paths[f.ci.pointerChain[len(f.ci.pointerChain)-1]]
+		if path == "" {
+			path = rootPath
+		}
+		f.theme.start(f, tokenCircular)
+		f.Write(flatShownAtBytes)
+		io.WriteString(f, path)
+		f.Write(closeAngleBytes)
+		f.theme.end(f, tokenCircular)
+
+	default:
+		for _, addr := range f.ci.pointerChain {
+			f.paths[addr] = f.path
+		}
+		f.flat(ve)
+	}
+}
+
+// startLine starts the line of the value at path, which is written along
+// with the first write of the value.
+func (f *flatState) startLine(path string) {
+	f.path = path
+	f.pending = true
+}
+
+// endLine ends the line of the current value unless nothing was written for
+// it, such as for containers which only wrote the lines of their entries.
+func (f *flatState) endLine() {
+	if f.open {
+		f.w.Write(newlineBytes)
+	}
+	f.pending = false
+	f.open = false
+}
+
+// flatLine writes the value v at path as a line or, for non-empty
+// containers, as the lines of its entries.
+func (f *flatState) flatLine(path string, v reflect.Value) {
+	f.startLine(path)
+	f.flat(v)
+	f.endLine()
+}
+
+// flat is the main workhorse for writing a value in flat form.  It uses the
+// passed reflect value to figure out what kind of object we are dealing with
+// and writes it through printValue, which calls back into the flatState for
+// arrays, slices, maps and structs.  Leaf values end the line started for
+// them by flatLine.
+func (f *flatState) flat(v reflect.Value) {
+	// Handle invalid reflect values immediately.
+	kind := v.Kind()
+	if kind == reflect.Invalid {
+		f.theme.writeToken(f, tokenNil, invalidAngleBytes)
+		return
+	}
+
+	// Call the formatter func registered for the type of the value.
+	if fn := f.cs.formatterOf(v); fn != nil {
+		fn(f, v, &ValueState{Depth: f.depth, Inline: true, p: f})
+		return
+	}
+
+	// Handle pointers specially.
+	if kind == reflect.Ptr {
+		f.flatPtr(v)
+		return
+	}
+
+	// Print integers and strings in hex as requested by a field tag.
+	if f.field.hex && printHexValue(f, v, kind, f.theme) {
+		return
+	}
+
+	printValue(f, f, v, kind, &f.methods, f.theme)
+}
+
+// printNested writes v inline on the current line in the style of the custom
+// formatter for formatter funcs.
+func (f *flatState) printNested(v reflect.Value) {
+	fs := formatState{
+		fs:    inlineState{f},
+		depth: f.depth + 1,
+		ci:    f.ci,
+		cs:    f.cs,
+		theme: f.theme,
+		field: f.field,
+	}
+	fs.format(fs.unpackValue(v))
+}
+
+// circular returns whether printNested would print the pointer v as a
+// circular reference.
+func (f *flatState) circular(v reflect.Value) bool {
+	return f.ci.circular(v, f.depth+1)
+}
+
+// enter increases the depth for the entries of a container and returns
+// whether they are within the depth limits.  The max depth marker is written
+// as the value of the container when they are not.
+func (f *flatState) enter() bool {
+	f.depth++
+	if f.field.maxDepthReached(f.cs, f.depth) {
+		f.theme.writeToken(f, tokenMaxDepth, maxBytes)
+		return false
+	}
+	return true
+}
+
+// flatElided writes the line of the marker of n elided entries of the
+// container at path.
+func (f *flatState) flatElided(path string, n int) {
+	f.startLine(path)
+	printElided(f, n)
+	f.endLine()
+}
+
+func (f *flatState) printArray(v reflect.Value) {
+	if buf, ok := byteSliceOf(f.cs, v); ok {
+		m := f.field.byteMode(f.cs).resolve(buf)
+		if m == BytesDefault || m == BytesHexdump {
+			m = BytesHex
+		}
+		shown := f.field.truncateBytes(f.cs, buf, m)
+		b := bufferGet()
+		defer bufferPut(b)
+		b.SetBytes(appendBytes(b.Bytes(), shown, m))
+		f.theme.writeToken(f, tokenString, b.Bytes())
+		if len(shown) < len(buf) {
+			printElided(f, len(buf)-len(shown))
+		}
+		return
+	}
+
+	numEntries := v.Len()
+	if numEntries == 0 {
+		f.Write(flatEmptyListBytes)
+		return
+	}
+	if f.enter() {
+		path := f.path
+		head, tail := f.field.span(f.cs, numEntries)
+		for i := 0; i < numEntries; i++ {
+			if i == head && head+tail < numEntries {
+				f.flatElided(path, numEntries-head-tail)
+				if tail == 0 {
+					break
+				}
+				i = numEntries - tail
+			}
+			f.flatLine(indexPath(path, i), f.unpackValue(v.Index(i)))
+		}
+	}
+	f.depth--
+}
+
+func (f *flatState) printString(v reflect.Value) {
+	b := bufferGet()
+	defer bufferPut(b)
+	s := f.field.truncateString(f.cs, v.String())
+	b.SetBytes(strconv.AppendQuote(b.Bytes(), s))
+	f.theme.writeToken(f, tokenString, b.Bytes())
+	if len(s) < v.Len() {
+		printElided(f, v.Len()-len(s))
+	}
+}
+
+func (f *flatState) printMap(v reflect.Value) {
+	numEntries := v.Len()
+	if numEntries == 0 {
+		f.Write(flatEmptyMapBytes)
+		return
+	}
+	if f.enter() {
+		path := f.path
+		keys := v.MapKeys()
+		if f.cs.SortKeys {
+			sortValues(keys, f.cs)
+		}
+		head, tail := f.field.span(f.cs, numEntries)
+		for i := 0; i < numEntries; i++ {
+			if i == head && head+tail < numEntries {
+				f.flatElided(path, numEntries-head-tail)
+				if tail == 0 {
+					break
+				}
+				i = numEntries - tail
+			}
+			key := keys[i]
+			kp := keyPath(f.cs, path, key)
+			if f.cs.redactsKey(key) {
+				f.flatRedacted(kp, f.unpackValue(v.MapIndex(key)))
+				continue
+			}
+			f.flatLine(kp, f.unpackValue(v.MapIndex(key)))
+		}
+	}
+	f.depth--
+}
+
+func (f *flatState) printStruct(v reflect.Value) {
+	vt := v.Type()
+	opts := fieldOptionsOf(vt)
+	printed := 0
+	for i, o := range opts {
+		if !o.skip && !(o.omitEmpty && v.Field(i).IsZero()) {
+			printed++
+		}
+	}
+	if printed == 0 {
+		f.Write(flatEmptyBytes)
+		return
+	}
+	if f.enter() {
+		path := f.path
+		field := f.field
+		for i, o := range opts {
+			vf := v.Field(i)
+			if o.skip || o.omitEmpty && vf.IsZero() {
+				continue
+			}
+			name := vt.Field(i).Name
+			fp := fieldPath(path, name)
+			if f.cs.redactsField(o, name) {
+				f.flatRedacted(fp, f.unpackValue(vf))
+				continue
+			}
+			f.field = field.enter(o, f.depth)
+			f.flatLine(fp, f.unpackValue(vf))
+		}
+		f.field = field
+	}
+	f.depth--
+}
+
+// flatRedacted writes the line of a redacted struct field or map entry at
+// path.
+func (f *flatState) flatRedacted(path string, v reflect.Value) {
+	f.startLine(path)
+	printRedacted(f, v, f.theme)
+	f.endLine()
+}
+
+func (f *flatState) defaultFormat() string {
+	return "%v"
+}
+
+// fflat is a helper function to consolidate the logic from the various public
+// methods which take varying writers and config states.
+func fflat(cs *ConfigState, w io.Writer, a ...interface{}) {
+	f := flatStatePool.Get().(*flatState)
+	defer flatStatePut(f)
+	f.w = w
+	f.cs = cs
+	f.theme = cs.theme(w)
+	f.ci = cycleInfoGet()
+	if f.paths == nil {
+		f.paths = make(map[uintptr]string)
+	}
+
+	// The results of the error and Stringer interfaces are written as the
+	// value of a line, so they are always requested without the decoration
+	// ContinueOnMethod adds.
+	f.methods = *cs
+	f.methods.ContinueOnMethod = false
+
+	for _, arg := range a {
+		f.ci.resetPointers()
+		f.depth = 0
+		f.field = fieldState{}
+		if arg == nil {
+			f.startLine("")
+			f.theme.writeToken(f, tokenNil, nilAngleBytes)
+			f.endLine()
+			continue
+		}
+		f.flatLine("", reflect.ValueOf(arg))
+	}
+}
+
+var flatStatePool = sync.Pool{New: func() interface{} {
+	return new(flatState)
+}}
+
+func flatStatePut(f *flatState) {
+	cycleInfoPut(f.ci)
+	for k := range f.paths {
+		delete(f.paths, k)
+	}
+	f.w = nil
+	f.ci = nil
+	f.cs = nil
+	f.methods = ConfigState{}
+	f.theme = nil
+	f.path = ""
+	flatStatePool.Put(f)
+}
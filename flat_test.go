@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spewerspew/spew"
+)
+
+// flatTests houses the tests to be performed against Fflat.
+var flatTests []renderTest
+
+// addFlatTest is a helper method to append the passed config, input and
+// desired result to flatTests.
+func addFlatTest(cs *spew.ConfigState, in interface{}, want string) {
+	flatTests = append(flatTests, renderTest{cs, in, want})
+}
+
+func addLeafFlatTests() {
+	cs := &spew.ConfigState{SortKeys: true}
+	addFlatTest(cs, nil, ". = <nil>\n")
+	addFlatTest(cs, 5, ". = 5\n")
+	addFlatTest(cs, "a\n", ". = \"a\\n\"\n")
+	addFlatTest(cs, []int{}, ". = []\n")
+	addFlatTest(cs, []int(nil), ". = <nil>\n")
+	addFlatTest(cs, [2]int{1, 2}, "[0] = 1\n[1] = 2\n")
+	addFlatTest(cs, map[int]string{2: "b", 1: "a"}, "[1] = \"a\"\n[2] = \"b\"\n")
+}
+
+func addNestedFlatTests() {
+	type request struct {
+		Headers map[string][]string
+		Token   string
+		Body    []byte
+	}
+	type item struct {
+		Price float64
+		tags  []string
+	}
+	type order struct {
+		Req   request
+		Items []item
+		Empty struct{}
+		Meta  map[string]interface{}
+	}
+	in := order{
+		Req: request{
+			Headers: map[string][]string{"Accept": {"text/html"}, "X-Id": {"1", "2"}},
+			Token:   "secret",
+			Body:    []byte("hi"),
+		},
+		Items: []item{{Price: 9.99, tags: []string{"a"}}},
+		Meta:  map[string]interface{}{},
+	}
+	addFlatTest(&spew.ConfigState{SortKeys: true}, in,
+		"Req.Headers[\"Accept\"][0] = \"text/html\"\n"+
+			"Req.Headers[\"X-Id\"][0] = \"1\"\n"+
+			"Req.Headers[\"X-Id\"][1] = \"2\"\n"+
+			"Req.Token = \"secret\"\n"+
+			"Req.Body = 6869\n"+
+			"Items[0].Price = 9.99\n"+
+			"Items[0].tags[0] = \"a\"\n"+
+			"Empty = {}\n"+
+			"Meta = map[]\n")
+}
+
+func addPointerFlatTests() {
+	cs := &spew.ConfigState{SortKeys: true}
+	circ := nodeCycle()
+	addFlatTest(cs, (*node)(nil), ". = <nil>\n")
+	addFlatTest(cs, circ,
+		"Name = \"a\"\nNext.Name = \"b\"\nNext.Next = <already shown at .>\n")
+	addFlatTest(cs, []*node{circ.Next},
+		"[0].Name = \"b\"\n[0].Next.Name = \"a\"\n[0].Next.Next = <already shown at [0]>\n")
+}
+
+func addMethodFlatTests() {
+	addFlatTest(&spew.ConfigState{}, []interface{}{stringer("x"), customError(1), panicer(1)},
+		"[0] = stringer x\n[1] = error: 1\n[2] = (PANIC=test panic)1\n")
+}
+
+func addLimitFlatTests() {
+	addFlatTest(&spew.ConfigState{MaxDepth: 1}, [][]int{{1}}, "[0] = <max depth reached>\n")
+	addFlatTest(&spew.ConfigState{MaxElements: 2}, []int{1, 2, 3, 4},
+		"[0] = 1\n[1] = 2\n. = ...(2 more)\n")
+	addFlatTest(&spew.ConfigState{SortKeys: true,
+		RedactPolicy: &spew.RedactPolicy{Fields: spew.DefaultRedactFields}},
+		map[string]string{"token": "abc", "user": "me"},
+		"[\"token\"] = <redacted len=3>\n[\"user\"] = \"me\"\n")
+}
+
+func setupFlatTests() {
+	if len(flatTests) == 0 {
+		addLeafFlatTests()
+		addNestedFlatTests()
+		addPointerFlatTests()
+		addMethodFlatTests()
+		addLimitFlatTests()
+	}
+}
+
+// TestFlat executes all of the tests described by flatTests.
+func TestFlat(t *testing.T) {
+	setupFlatTests()
+
+	t.Logf("Running %d tests", len(flatTests))
+	for i, test := range flatTests {
+		buf := new(bytes.Buffer)
+		test.cs.Fflat(buf, test.in)
+		if s := buf.String(); s != test.want {
+			t.Errorf("Flat #%d\n got: %q want: %q", i, s, test.want)
+		}
+	}
+}
+
+// TestFlatArguments ensures every argument is written with paths starting at
+// its own root.
+func TestFlatArguments(t *testing.T) {
+	s := spew.Sflat(1, []string{"a"}, nil)
+	want := ". = 1\n[0] = \"a\"\n. = <nil>\n"
+	if s != want {
+		t.Errorf("Flat arguments\n got: %q want: %q", s, want)
+	}
+}
@@ -18,6 +18,7 @@
 package spew
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"reflect"
@@ -40,6 +41,30 @@ type formatState struct {
 	ignoreNextType bool
 	ci             *cycleInfo
 	cs             *ConfigState
+	theme          *Theme
+	field          fieldState
+	inline         bool
+	fit            *fitWriter
+	labels         *pointerLabels
+	ids            *pointerIDs
+	stack          walkStack
+	budget         outputBudget
+	count          countState
+	ctx            context.Context
+	stats          *methodStats
+}
+
+// countState counts the bytes written to the fmt.State it wraps for the
+// MaxBytes option.
+type countState struct {
+	fmt.State
+	n int64
+}
+
+func (c *countState) Write(p []byte) (int, error) {
+	n, err := c.State.Write(p)
+	c.n += int64(n)
+	return n, err
 }
 
 // buildDefaultFormat recreates the original format string without precision
@@ -108,7 +133,9 @@ func (f *formatState) formatPtr(v reflect.Value) {
 	// Display nil if top level pointer is nil.
 	showTypes := f.fs.Flag('#')
 	if v.IsNil() && (!showTypes || f.ignoreNextType) {
-		f.fs.Write(nilAngleBytes)
+		if !handleNilMethods(f.cs, f.fs, v, f.stats, f.theme) {
+			f.theme.writeToken(f.fs, tokenNil, nilAngleBytes)
+		}
 		return
 	}
 
@@ -118,6 +145,7 @@ func (f *formatState) formatPtr(v reflect.Value) {
 	ve := derefPtr(v, f.depth, f.ci)
 
 	// Display type or indirection level depending on flags.
+	f.theme.start(f.fs, tokenType)
 	if showTypes && !f.ignoreNextType {
 		f.fs.Write(openParenBytes)
 		for i := 0; i < f.ci.indirects; i++ {
@@ -135,15 +163,19 @@ func (f *formatState) formatPtr(v reflect.Value) {
 		}
 		f.fs.Write(closeAngleBytes)
 	}
+	f.theme.end(f.fs, tokenType)
 
 	// Display pointer information depending on flags.
-	if f.fs.Flag('+') && (len(f.ci.pointerChain) > 0) {
+	showAddrs := f.fs.Flag('+') && !(f.inline && f.cs.DisablePointerAddresses)
+	if showAddrs && (len(f.ci.pointerChain) > 0) {
 		f.fs.Write(openParenBytes)
 		for i, addr := range f.ci.pointerChain {
 			if i > 0 {
 				f.fs.Write(pointerChainBytes)
 			}
-			printHexPtr(f.fs, addr)
+			f.theme.start(f.fs, tokenPointer)
+			f.ids.print(f.fs, addr)
+			f.theme.end(f.fs, tokenPointer)
 		}
 		f.fs.Write(closeParenBytes)
 	}
@@ -151,26 +183,101 @@ func (f *formatState) formatPtr(v reflect.Value) {
 	// Display dereferenced value.
 	switch {
 	case f.ci.nilFound:
-		f.fs.Write(nilAngleBytes)
+		if !handleNilMethods(f.cs, f.fs, ve, f.stats, f.theme) {
+			f.theme.writeToken(f.fs, tokenNil, nilAngleBytes)
+		}
 
 	case f.ci.cycleFound:
-		f.fs.Write(circularShortBytes)
+		if f.labels.enabled() {
+			if n, ok := f.labels.labels[pointerKey(ve)]; ok {
+				printLabel(f.fs, '*', n, f.theme)
+				break
+			}
+		}
+		f.theme.writeToken(f.fs, tokenCircular, circularShortBytes)
 
 	default:
+		if f.labels.enabled() {
+			key := ptrKey{f.ci.pointerChain[len(f.ci.pointerChain)-1], ve.Type()}
+			n, seen := f.labels.label(key)
+			if seen {
+				printLabel(f.fs, '*', n, f.theme)
+				break
+			}
+			if n != 0 {
+				printLabel(f.fs, '&', n, f.theme)
+				f.fs.Write(spaceBytes)
+			}
+		}
 		f.ignoreNextType = true
-		f.format(ve)
+		f.formatValue(ve)
 	}
 }
 
 // format is the main workhorse for providing the Formatter interface.  It
-// uses the passed reflect value to figure out what kind of object we are
-// dealing with and formats it appropriately.  It is a recursive function,
-// however circular data structures are detected and handled properly.
+// writes v with formatValue and then steps the frames it pushed until v and
+// everything it contains is written.  The traversal isn't recursive, so the
+// depth of v is only limited by the heap, and circular data structures are
+// detected and handled properly.
 func (f *formatState) format(v reflect.Value) {
+	base := len(f.stack)
+	f.formatValue(v)
+	for len(f.stack) > base {
+		// Stop writing values inline in Dump output once they don't fit.
+		if f.fit != nil && f.fit.overflow {
+			f.unwind(base)
+			return
+		}
+		fr := f.stack.top()
+		switch fr.kind {
+		case reflect.Array:
+			f.stepArray(fr)
+		case reflect.Map:
+			f.stepMap(fr)
+		case reflect.Struct:
+			f.stepStruct(fr)
+		}
+	}
+}
+
+// unwind removes the frames above base without writing the rest of their
+// values, restoring the depth and field state they changed.
+func (f *formatState) unwind(base int) {
+	for len(f.stack) > base {
+		fr := f.stack.top()
+		if fr.kind == reflect.Struct {
+			f.field = fr.field
+		}
+		f.depth--
+		f.stack.pop()
+	}
+}
+
+// formatValue uses the passed reflect value to figure out what kind of
+// object we are dealing with and formats it appropriately.  Arrays, slices,
+// maps and structs push a frame for the values they contain, which are
+// written by the following steps of format.
+func (f *formatState) formatValue(v reflect.Value) {
+	// Stop writing values inline in Dump output once they don't fit.
+	if f.fit != nil && f.fit.overflow {
+		return
+	}
+
 	// Handle invalid reflect values immediately.
 	kind := v.Kind()
 	if kind == reflect.Invalid {
-		f.fs.Write(invalidAngleBytes)
+		f.theme.writeToken(f.fs, tokenNil, invalidAngleBytes)
+		return
+	}
+	f.budget.nodes++
+
+	// Call the formatter func registered for the type of the value.
+	if fn := f.cs.formatterOf(v); fn != nil {
+		if !f.ignoreNextType && f.fs.Flag('#') {
+			f.writeType(v.Type())
+		}
+		f.ignoreNextType = false
+		fn(f.fs, v, &ValueState{Depth: f.depth, Inline: true, p: f})
 		return
 	}
 
@@ -182,13 +289,40 @@ func (f *formatState) format(v reflect.Value) {
 
 	// Print type information unless already handled elsewhere.
 	if !f.ignoreNextType && f.fs.Flag('#') {
-		f.fs.Write(openParenBytes)
-		io.WriteString(f.fs, v.Type().String())
-		f.fs.Write(closeParenBytes)
+		f.writeType(v.Type())
 	}
 	f.ignoreNextType = false
 
-	printValue(f.fs, f, v, kind, f.cs)
+	// Print integers and strings in hex as requested by a field tag.
+	if f.field.hex && printHexValue(f.fs, v, kind, f.theme) {
+		return
+	}
+
+	printValue(f.fs, f, v, kind, f.cs, f.theme)
+}
+
+// writeType writes the type t in parentheses.
+func (f *formatState) writeType(t reflect.Type) {
+	f.theme.start(f.fs, tokenType)
+	f.fs.Write(openParenBytes)
+	io.WriteString(f.fs, t.String())
+	f.fs.Write(closeParenBytes)
+	f.theme.end(f.fs, tokenType)
+}
+
+// printNested formats v one level deeper than the current value for
+// formatter funcs.  The value is written entirely before printNested returns,
+// on top of the frames of the current traversal.
+func (f *formatState) printNested(v reflect.Value) {
+	f.depth++
+	f.format(f.unpackValue(v))
+	f.depth--
+}
+
+// circular returns whether printNested would print the pointer v as a
+// circular reference.
+func (f *formatState) circular(v reflect.Value) bool {
+	return f.ci.circular(v, f.depth+1)
 }
 
 // Format satisfies the fmt.Formatter interface. See NewFormatter for usage
@@ -205,9 +339,9 @@ func (f *formatState) Format(fs fmt.State, verb rune) {
 
 	if f.value == nil {
 		if fs.Flag('#') {
-			fs.Write(interfaceBytes)
+			f.theme.writeToken(fs, tokenType, interfaceBytes)
 		}
-		fs.Write(nilAngleBytes)
+		f.theme.writeToken(fs, tokenNil, nilAngleBytes)
 		return
 	}
 
@@ -215,82 +349,267 @@ func (f *formatState) Format(fs fmt.State, verb rune) {
 	defer func() {
 		cycleInfoPut(f.ci)
 		f.ci = nil
+		f.stack.reset()
+		f.labels = nil
+		f.ids = nil
+		f.count = countState{}
 	}()
+	if f.budget.reset(f.cs, f.ctx); f.budget.enabled() {
+		f.count.State = fs
+		f.fs = &f.count
+	}
+	if f.cs.ReferenceLabels {
+		f.labels = new(pointerLabels)
+		f.labels.reset(f.value)
+	}
+	if f.cs.SymbolicPointers {
+		f.ids = new(pointerIDs)
+		f.ids.reset()
+	}
 	f.format(reflect.ValueOf(f.value))
+	if f.budget.ctxErr != nil {
+		f.fs.Write(spaceBytes)
+		printCanceled(f.fs, f.budget.ctxErr, f.theme)
+	}
 }
 
 func (f *formatState) printArray(v reflect.Value) {
+	if m := f.field.byteMode(f.cs); m != BytesDefault {
+		if buf, ok := byteSliceOf(f.cs, v); ok {
+			f.formatBytes(buf, m.resolve(buf))
+			return
+		}
+	}
+
 	f.fs.Write(openBracketBytes)
-	f.depth++
-	if (f.cs.MaxDepth != 0) && (f.depth > f.cs.MaxDepth) {
-		f.fs.Write(maxShortBytes)
+	if f.enter() {
+		f.stack.push(reflect.Array, v)
 	} else {
-		numEntries := v.Len()
-		for i := 0; i < numEntries; i++ {
-			if i > 0 {
-				f.fs.Write(spaceBytes)
-			}
-			f.ignoreNextType = true
-			f.format(f.unpackValue(v.Index(i)))
+		f.fs.Write(closeBracketBytes)
+	}
+}
+
+// enter increases the depth for the entries of an array, slice, map or
+// struct and returns whether they are within the depth limits.  The max
+// depth marker is written and the depth restored when they are not.
+func (f *formatState) enter() bool {
+	f.depth++
+	if f.field.maxDepthReached(f.cs, f.depth) {
+		f.theme.writeToken(f.fs, tokenMaxDepth, maxShortBytes)
+		f.depth--
+		return false
+	}
+	return true
+}
+
+// nextEntry returns the index of the next entry of the frame fr, which has n
+// entries, after writing the separator preceding it and the marker of the
+// entries elided before it.  The index is n once all entries are written.
+func (f *formatState) nextEntry(fr *walkFrame, n int) int {
+	i := fr.next
+	if i >= n {
+		return n
+	}
+	if f.budget.spent(f.count.n + sepLen(i > 0, spaceBytes)) {
+		f.formatTruncated(n-i, i > 0)
+		return n
+	}
+	if i > 0 {
+		f.fs.Write(spaceBytes)
+	}
+	head, tail := f.field.span(f.cs, n)
+	if i == head && head+tail < n {
+		printElided(f.fs, n-head-tail)
+		if tail == 0 {
+			return n
 		}
+		f.fs.Write(spaceBytes)
+		i = n - tail
 	}
-	f.depth--
-	f.fs.Write(closeBracketBytes)
+	return i
+}
+
+// formatTruncated writes the marker of n entries skipped after the output
+// budget was used up, preceded by a separator when sep is set.  Nothing is
+// written once the context of the operation is canceled, which is marked at
+// its end.
+func (f *formatState) formatTruncated(n int, sep bool) {
+	if f.budget.ctxErr != nil {
+		return
+	}
+	if sep {
+		f.fs.Write(spaceBytes)
+	}
+	printTruncated(f.fs, n, f.theme)
+}
+
+// stepArray starts the next element of the array or slice of the frame fr
+// or ends it once all of its elements are written.
+func (f *formatState) stepArray(fr *walkFrame) {
+	numEntries := fr.v.Len()
+	i := f.nextEntry(fr, numEntries)
+	if i >= numEntries {
+		f.stack.pop()
+		f.depth--
+		f.fs.Write(closeBracketBytes)
+		return
+	}
+	fr.next = i + 1
+	f.ignoreNextType = true
+	f.formatValue(f.unpackValue(fr.v.Index(i)))
 }
 
 func (f *formatState) printString(v reflect.Value) {
-	io.WriteString(f.fs, v.String())
+	f.formatString(v.String())
+}
+
+// formatString writes s truncated according to the maxlen directive of a
+// field tag.
+func (f *formatState) formatString(s string) {
+	shown := f.field.truncateString(f.cs, s)
+	if n := f.budget.limit(f.count.n, 1); n >= 0 {
+		shown = runePrefix(shown, n)
+	}
+	f.theme.start(f.fs, tokenString)
+	io.WriteString(f.fs, shown)
+	f.theme.end(f.fs, tokenString)
+	if len(shown) < len(s) {
+		printElided(f.fs, len(s)-len(shown))
+	}
+}
+
+// formatBytes writes the byte array or slice buf in mode m as requested by the
+// ByteMode option or the bytes directive of a field tag.  Hexdumps are written
+// as compact hex strings.
+func (f *formatState) formatBytes(buf []byte, m ByteMode) {
+	if m == BytesHexdump {
+		m = BytesHex
+	}
+	shown := f.field.truncateBytes(f.cs, buf, m)
+	if n := f.budget.limit(f.count.n, 2); n >= 0 && len(shown) > n {
+		shown = shown[:n]
+	}
+	b := bufferGet()
+	defer bufferPut(b)
+	b.SetBytes(appendBytes(b.Bytes(), shown, m))
+	f.theme.writeToken(f.fs, tokenString, b.Bytes())
+	if len(shown) < len(buf) {
+		printElided(f.fs, len(buf)-len(shown))
+	}
 }
 
 func (f *formatState) printMap(v reflect.Value) {
 	f.fs.Write(openMapBytes)
-	f.depth++
-	if (f.cs.MaxDepth != 0) && (f.depth > f.cs.MaxDepth) {
-		f.fs.Write(maxShortBytes)
-	} else {
-		keys := v.MapKeys()
-		if f.cs.SortKeys {
-			sortValues(keys, f.cs)
-		}
-		for i, key := range keys {
-			if i > 0 {
-				f.fs.Write(spaceBytes)
-			}
-			f.ignoreNextType = true
-			f.format(f.unpackValue(key))
-			f.fs.Write(colonBytes)
-			f.ignoreNextType = true
-			f.format(f.unpackValue(v.MapIndex(key)))
+	if !f.enter() {
+		f.fs.Write(closeMapBytes)
+		return
+	}
+	fr := f.stack.push(reflect.Map, v)
+	fr.keys = v.MapKeys()
+	if f.cs.SortKeys {
+		sortValues(fr.keys, f.cs)
+	}
+}
+
+// stepMap writes the value of the map entry of the frame fr whose key was
+// written last or starts the next entry, or ends the map once all of its
+// entries are written.
+func (f *formatState) stepMap(fr *walkFrame) {
+	if fr.value {
+		fr.value = false
+		key := fr.keys[fr.next-1]
+		f.fs.Write(colonBytes)
+		if f.cs.redactsKey(key) {
+			f.formatRedacted(f.unpackValue(fr.v.MapIndex(key)))
+			return
 		}
+		f.ignoreNextType = true
+		f.formatValue(f.unpackValue(fr.v.MapIndex(key)))
+		return
 	}
-	f.depth--
-	f.fs.Write(closeMapBytes)
+	numEntries := len(fr.keys)
+	i := f.nextEntry(fr, numEntries)
+	if i >= numEntries {
+		f.stack.pop()
+		f.depth--
+		f.fs.Write(closeMapBytes)
+		return
+	}
+	fr.next = i + 1
+	fr.value = true
+	f.ignoreNextType = true
+	f.formatValue(f.unpackValue(fr.keys[i]))
 }
 
 func (f *formatState) printStruct(v reflect.Value) {
-	numFields := v.NumField()
 	f.fs.Write(openBraceBytes)
-	f.depth++
-	if (f.cs.MaxDepth != 0) && (f.depth > f.cs.MaxDepth) {
-		f.fs.Write(maxShortBytes)
-	} else {
-		vt := v.Type()
-		for i := 0; i < numFields; i++ {
-			if i > 0 {
-				f.fs.Write(spaceBytes)
-			}
-			vtf := vt.Field(i)
-			if f.fs.Flag('+') || f.fs.Flag('#') {
-				io.WriteString(f.fs, vtf.Name)
-				f.fs.Write(colonBytes)
-			}
-			f.format(f.unpackValue(v.Field(i)))
+	if !f.enter() {
+		f.fs.Write(closeBraceBytes)
+		return
+	}
+	fr := f.stack.push(reflect.Struct, v)
+	fr.field = f.field
+}
+
+// stepStruct starts the next field of the struct of the frame fr or ends the
+// struct once all of its fields are written.
+func (f *formatState) stepStruct(fr *walkFrame) {
+	vt := fr.v.Type()
+	opts := fieldOptionsOf(vt)
+	for fr.next < len(opts) {
+		i := fr.next
+		fr.next++
+		o := opts[i]
+		vf := fr.v.Field(i)
+		if o.skip || o.omitEmpty && vf.IsZero() {
+			continue
+		}
+		if f.budget.spent(f.count.n + sepLen(fr.printed > 0, spaceBytes)) {
+			f.formatTruncated(1+fieldsLeft(fr.v, opts, fr.next), fr.printed > 0)
+			break
+		}
+		if fr.printed > 0 {
+			f.fs.Write(spaceBytes)
+		}
+		fr.printed++
+		vtf := vt.Field(i)
+		if f.fs.Flag('+') || f.fs.Flag('#') {
+			f.theme.start(f.fs, tokenField)
+			io.WriteString(f.fs, vtf.Name)
+			f.theme.end(f.fs, tokenField)
+			f.fs.Write(colonBytes)
+		}
+		if f.cs.redactsField(o, vtf.Name) {
+			f.formatRedacted(f.unpackValue(vf))
+			continue
 		}
+		f.field = fr.field.enter(o, f.depth)
+		f.formatValue(f.unpackValue(vf))
+		return
 	}
+	f.field = fr.field
+	f.stack.pop()
 	f.depth--
 	f.fs.Write(closeBraceBytes)
 }
 
+// formatRedacted writes a marker instead of the value of v for redacted struct
+// fields and map entries.
+func (f *formatState) formatRedacted(v reflect.Value) {
+	if f.fs.Flag('#') {
+		f.theme.start(f.fs, tokenType)
+		f.fs.Write(openParenBytes)
+		io.WriteString(f.fs, v.Type().String())
+		f.fs.Write(closeParenBytes)
+		f.theme.end(f.fs, tokenType)
+	}
+	printRedacted(f.fs, v, f.theme)
+}
+
+func (f *formatState) methodStats() *methodStats {
+	return f.stats
+}
+
 func (f *formatState) defaultFormat() string {
 	return f.buildDefaultFormat()
 }
@@ -303,9 +622,20 @@ func newFormatter(cs *ConfigState, v interface{}) fmt.Formatter {
 	return &f
 }
 
-// Reset resets the formatter state.
+// newFormatterContext is a helper function to consolidate the logic from the
+// various public methods which take varying contexts and config states.
+func newFormatterContext(ctx context.Context, cs *ConfigState, v interface{}) fmt.Formatter {
+	var f formatState
+	f.Reset(cs, v)
+	f.ctx = ctx
+	return &f
+}
+
+// Reset resets the formatter state.  The formatter doesn't know the writer
+// its output ends up in, so it's only colored when cs.ForceColor is set.
 func (f *formatState) Reset(cs *ConfigState, v interface{}) {
-	*f = formatState{value: v, cs: cs}
+	f.stack.reset()
+	*f = formatState{value: v, cs: cs, theme: cs.theme(nil), stack: f.stack}
 }
 
 /*
@@ -329,10 +659,18 @@ func NewFormatter(v interface{}) fmt.Formatter {
 	return newFormatter(&Config, v)
 }
 
+// NewFormatterContext returns a custom formatter exactly the same as
+// NewFormatter which stops formatting once ctx is done.  The containers being
+// written are closed with a marker of the number of skipped entries and the
+// output ends with a marker of the error of ctx.
+func NewFormatterContext(ctx context.Context, v interface{}) fmt.Formatter {
+	return newFormatterContext(ctx, &Config, v)
+}
+
 var formattersPool sync.Pool
 
 func formattersPut(pv interface{}) { formattersPool.Put(pv) }
-func formattersGet(cs *ConfigState, args []interface{}) (pv interface{}, formatters []interface{}) {
+func formattersGet(cs *ConfigState, w io.Writer, args []interface{}) (pv interface{}, formatters []interface{}) {
 	pv = formattersPool.Get()
 	if pv != nil {
 		formatters = pv.([]interface{})
@@ -351,8 +689,11 @@ func formattersGet(cs *ConfigState, args []interface{}) (pv interface{}, formatt
 	if len(formatters) > len(args) {
 		formatters = formatters[:len(args)]
 	}
+	theme := cs.theme(w)
 	for i, arg := range args {
-		formatters[i].(*formatState).Reset(cs, arg)
+		f := formatters[i].(*formatState)
+		f.Reset(cs, arg)
+		f.theme = theme
 	}
 	return pv, formatters
 }
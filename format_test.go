@@ -69,8 +69,11 @@ package spew_test
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"runtime/debug"
+	"strings"
 	"testing"
 	"unsafe"
 
@@ -1036,6 +1039,26 @@ func addStructFormatterTests() {
 	addFormatterTest("%#+v", nv4, "(*"+v4t+")"+"<nil>")
 }
 
+func addTagFormatterTests() {
+	v := tagged{
+		Skipped:  1,
+		Password: "hunter2",
+		Flags:    0xbeef,
+		Long:     "abcdef",
+		Many:     []int{1, 2, 3, 4},
+		Deep:     [][]int{{1}},
+		Raw:      []byte("raw"),
+		Plain:    5,
+	}
+	vt := "spew_test.tagged"
+	addFormatterTest("%v", v, "{<redacted len=7> 0xbeef abc...(3 more) [1 2 ...(2 more)] [[<max>]] \"raw\" 5}")
+	addFormatterTest("%+v", v, "{Password:<redacted len=7> Flags:0xbeef Long:abc...(3 more) "+
+		"Many:[1 2 ...(2 more)] Deep:[[<max>]] Raw:\"raw\" Plain:5}")
+	addFormatterTest("%#v", v, "("+vt+"){Password:(string)<redacted len=7> Flags:(uint16)0xbeef "+
+		"Long:(string)abc...(3 more) Many:([]int)[1 2 ...(2 more)] Deep:([][]int)[[<max>]] "+
+		"Raw:([]uint8)\"raw\" Plain:(int)5}")
+}
+
 func addUintptrFormatterTests() {
 	// Null pointer.
 	v := uintptr(0)
@@ -1478,6 +1501,7 @@ func setupFormatterTests() {
 		addInterfaceFormatterTests()
 		addMapFormatterTests()
 		addStructFormatterTests()
+		addTagFormatterTests()
 		addUintptrFormatterTests()
 		addUnsafePointerFormatterTests()
 		addChanFormatterTests()
@@ -1592,3 +1616,181 @@ func TestPrintSortedKeys(t *testing.T) {
 		t.Errorf("Sorted keys mismatch 6:\n  %v %v", s, expected)
 	}
 }
+
+func TestPrintMaxElements(t *testing.T) {
+	cfg := spew.ConfigState{SortKeys: true, MaxElements: 2, MaxStringLen: 4}
+	s := cfg.Sprint([]int{1, 2, 3, 4, 5})
+	expected := "[1 2 ...(3 more)]"
+	if s != expected {
+		t.Errorf("Max elements mismatch 1:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sprint(map[string]int{"a": 1, "b": 2, "c": 3})
+	expected = "map[a:1 b:2 ...(1 more)]"
+	if s != expected {
+		t.Errorf("Max elements mismatch 2:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sprint("hééllo")
+	expected = "hé...(5 more)"
+	if s != expected {
+		t.Errorf("Max string length mismatch 3:\n  %v %v", s, expected)
+	}
+
+	cfg.TailElements = 1
+	s = cfg.Sprint([]int{1, 2, 3, 4, 5})
+	expected = "[1 2 ...(2 more) 5]"
+	if s != expected {
+		t.Errorf("Tail elements mismatch 4:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sprint(map[string]int{"a": 1, "b": 2, "c": 3, "d": 4})
+	expected = "map[a:1 b:2 ...(1 more) d:4]"
+	if s != expected {
+		t.Errorf("Tail elements mismatch 5:\n  %v %v", s, expected)
+	}
+
+	cfg.MaxElements = 0
+	s = cfg.Sprint([]int{1, 2, 3})
+	expected = "[1 2 3]"
+	if s != expected {
+		t.Errorf("Tail elements mismatch 6:\n  %v %v", s, expected)
+	}
+}
+
+func TestPrintByteMode(t *testing.T) {
+	tests := []struct {
+		mode     spew.ByteMode
+		in       []byte
+		expected string
+	}{
+		{spew.BytesDefault, []byte("hi"), "[104 105]"},
+		{spew.BytesHexdump, []byte("hi"), "6869"},
+		{spew.BytesString, []byte("hi\n"), `"hi\n"`},
+		{spew.BytesString, []byte("hello\x00\x1b[2J"), `"hello\x00\x1b[2J"`},
+		{spew.BytesString, []byte{0xff}, "ff"},
+		{spew.BytesBase64, []byte{0xff, 0}, "/wA="},
+		{spew.BytesHex, []byte{0xff, 0}, "ff00"},
+		{spew.BytesAuto, []byte("héllo"), `"héllo"`},
+		{spew.BytesAuto, []byte{0xff, 0}, "ff00"},
+	}
+	for i, test := range tests {
+		cfg := spew.ConfigState{ByteMode: test.mode}
+		if s := cfg.Sprint(test.in); s != test.expected {
+			t.Errorf("Byte mode mismatch %d:\n  %v %v", i, s, test.expected)
+		}
+	}
+}
+
+func TestPrintReferenceLabels(t *testing.T) {
+	shared := &labelNode{V: 2}
+	root := &labelNode{V: 1, L: shared, R: shared}
+	shared.L = root
+
+	cfg := spew.ConfigState{ReferenceLabels: true}
+	s := cfg.Sprint(root)
+	expected := "<*>&1 {1 <*>&2 {2 <*>*1 <nil>} <*>*2}"
+	if s != expected {
+		t.Errorf("Reference labels mismatch 1:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sprintf("%#v", []*labelNode{shared, shared})
+	expected = "([]*spew_test.labelNode)[<*>&1 {V:(int)2 " +
+		"L:(*spew_test.labelNode){V:(int)1 L:(*spew_test.labelNode)*1 " +
+		"R:(*spew_test.labelNode)*1} R:(*spew_test.labelNode)<nil>} <*>*1]"
+	if s != expected {
+		t.Errorf("Reference labels mismatch 2:\n  %v %v", s, expected)
+	}
+}
+
+func TestPrintSymbolicPointers(t *testing.T) {
+	shared := &labelNode{V: 2}
+	root := &labelNode{V: 1, L: shared, R: shared}
+
+	cfg := spew.ConfigState{SymbolicPointers: true}
+	s := cfg.Sprintf("%+v", &root)
+	expected := "<**>(p1->p2){V:1 L:<*>(p3){V:2 L:<nil> R:<nil>} R:<*>(p3){V:2 L:<nil> R:<nil>}}"
+	if s != expected {
+		t.Errorf("Symbolic pointers mismatch 1:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sprintf("%v", root)
+	expected = "<*>{1 <*>{2 <nil> <nil>} <*>{2 <nil> <nil>}}"
+	if s != expected {
+		t.Errorf("Symbolic pointers mismatch 2:\n  %v %v", s, expected)
+	}
+}
+
+func TestPrintDeep(t *testing.T) {
+	// The traversal keeps its own stack, so values nested far deeper than
+	// the goroutine stack allows are formatted.
+	const n = 100000
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+
+	s := spew.Sprintf("%v", deepList(n))
+	if got := strings.Count(s, "<*>{"); got != n {
+		t.Errorf("Deep format has %d nodes, want %d", got, n)
+	}
+	expected := "<*>{100000 <nil> <nil>}" + strings.Repeat(" <nil>}", n-1)
+	if !strings.HasSuffix(s, expected) {
+		t.Errorf("Deep format mismatch:\n  %v %v", s[len(s)-len(expected):], expected)
+	}
+}
+
+func TestPrintBudget(t *testing.T) {
+	v := budgetValue{A: []int{1, 2, 3, 4, 5}, M: map[string]int{"a": 1, "b": 2}, S: "s"}
+
+	cfg := spew.ConfigState{MaxNodes: 4, SortKeys: true}
+	s := cfg.Sprintf("%v %+v", v, []int{1, 2, 3, 4, 5, 6})
+	expected := "{[1 2 <truncated: 3 more>] <truncated: 2 more>} [1 2 3 <truncated: 3 more>]"
+	if s != expected {
+		t.Errorf("Max nodes mismatch:\n  %v %v", s, expected)
+	}
+
+	cfg = spew.ConfigState{MaxBytes: 30, SortKeys: true}
+	s = cfg.Sprintf("%+v", v)
+	expected = "{A:[1 2 3 4 5] M:map[a:1 b:2] <truncated: 1 more>}"
+	if s != expected {
+		t.Errorf("Max bytes mismatch:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sprintf("%v", strings.Repeat("x", 100))
+	expected = strings.Repeat("x", 64) + "...(36 more)"
+	if s != expected {
+		t.Errorf("Max bytes string mismatch:\n  %v %v", s, expected)
+	}
+}
+
+func TestPrintContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := fmt.Sprintf("%v", spew.NewFormatterContext(ctx, []int{1, 2, 3}))
+	expected := "[] <canceled: context canceled>"
+	if s != expected {
+		t.Errorf("Canceled context mismatch:\n  %v %v", s, expected)
+	}
+
+	s = fmt.Sprintf("%v", spew.NewFormatterContext(context.Background(), []int{1, 2, 3}))
+	expected = "[1 2 3]"
+	if s != expected {
+		t.Errorf("Context mismatch:\n  %v %v", s, expected)
+	}
+}
+
+func TestPrintNilMethods(t *testing.T) {
+	var np *nilStringer
+	cfg := spew.ConfigState{NilMethods: spew.NilMethodsCall}
+	s := cfg.Sprintf("%v %+v", np, []*nilStringer{nil})
+	expected := "nil receiver [nil receiver]"
+	if s != expected {
+		t.Errorf("Nil methods mismatch:\n  %v %v", s, expected)
+	}
+
+	cfg.NilMethods = spew.NilMethodsAnnotate
+	cfg.MethodPolicy = &spew.MethodPolicy{Deny: []spew.MethodFilter{{Package: "github.com/spewerspew/spew_test"}}}
+	s = cfg.Sprintf("%v", np)
+	expected = "<nil>"
+	if s != expected {
+		t.Errorf("Denied nil methods mismatch:\n  %v %v", s, expected)
+	}
+}
@@ -0,0 +1,475 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Some constants in the form of bytes to avoid string overhead when writing Go
+// syntax.
+var (
+	nilBytes            = []byte("nil")
+	ampersandBytes      = []byte("&")
+	commentBytes        = []byte("// ")
+	funcOpenBytes       = []byte("func() ")
+	funcCloseBytes      = []byte("}()")
+	returnBytes         = []byte("return ")
+	helperDeclareBytes  = []byte(" := new(")
+	helperAssignBytes   = []byte(" = ")
+	openCommentBytes    = []byte(" /* ")
+	closeCommentBytes   = []byte(" */")
+	unexportedNoteBytes = []byte(" (unexported)")
+)
+
+// valueFormatter implements the fmt.Formatter interface for a reflect.Value,
+// which unlike NewFormatter doesn't require the value to be exported.
+type valueFormatter struct {
+	v  reflect.Value
+	cs *ConfigState
+}
+
+// Format satisfies the fmt.Formatter interface.
+func (vf valueFormatter) Format(fs fmt.State, verb rune) {
+	f := formatState{fs: fs, cs: vf.cs, ci: cycleInfoGet()}
+	defer cycleInfoPut(f.ci)
+	f.format(vf.v)
+}
+
+// goSyntaxState contains information about the state of a Go syntax dump
+// operation.
+type goSyntaxState struct {
+	w              io.Writer
+	depth          int
+	ignoreNextType bool
+	cs             *ConfigState
+	config         ConfigState
+	census         pointerCensus
+	helpers        map[ptrKey]string
+}
+
+// indent performs indentation according to the depth level and cs.Indent
+// option.
+func (g *goSyntaxState) indent() {
+	for i := 0; i < g.depth; i++ {
+		io.WriteString(g.w, g.cs.Indent)
+	}
+}
+
+// unpackValue returns values inside of non-nil interfaces when possible.  The
+// type of values which have been unpacked from an interface is written since
+// it can't be inferred from the context.
+func (g *goSyntaxState) unpackValue(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		g.ignoreNextType = false
+		v = v.Elem()
+	}
+	return v
+}
+
+// typeString returns the Go syntax of t.  Named types are qualified with
+// their package name unless they belong to cs.GoSyntaxPackage.
+func (g *goSyntaxState) typeString(t reflect.Type) string {
+	if t.Name() != "" {
+		if t.PkgPath() != "" && t.PkgPath() == g.cs.GoSyntaxPackage {
+			return t.Name()
+		}
+		return t.String()
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + g.typeString(t.Elem())
+	case reflect.Slice:
+		return "[]" + g.typeString(t.Elem())
+	case reflect.Array:
+		return "[" + strconv.Itoa(t.Len()) + "]" + g.typeString(t.Elem())
+	case reflect.Map:
+		return "map[" + g.typeString(t.Key()) + "]" + g.typeString(t.Elem())
+	}
+	return t.String()
+}
+
+// isLocal returns whether the struct type t belongs to cs.GoSyntaxPackage, so
+// its unexported fields can be written.
+func (g *goSyntaxState) isLocal(t reflect.Type) bool {
+	return g.cs.GoSyntaxPackage != "" && t.PkgPath() == g.cs.GoSyntaxPackage
+}
+
+// needsHelper returns whether the pointer v has to be written as a helper
+// variable instead of taking the address of a composite literal.  That is the
+// case for shared and circular pointers and pointers to values which can't be
+// written as composite literals.
+func (g *goSyntaxState) needsHelper(v reflect.Value) bool {
+	if g.census.counts[pointerKey(v)] > 1 {
+		return true
+	}
+	switch v.Type().Elem().Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.Struct:
+		return false
+	}
+	return true
+}
+
+// goPtr handles formatting of pointers as either a helper variable, the
+// address of a composite literal or a typed nil.
+func (g *goSyntaxState) goPtr(v reflect.Value, typed bool) {
+	if v.IsNil() {
+		g.goNil(v, typed)
+		return
+	}
+	if name, ok := g.helpers[pointerKey(v)]; ok {
+		io.WriteString(g.w, name)
+		return
+	}
+	g.w.Write(ampersandBytes)
+	g.ignoreNextType = true
+	g.gosyntax(v.Elem())
+}
+
+// goNil writes the nil value of v, which is converted to its type when it
+// can't be inferred from the context.
+func (g *goSyntaxState) goNil(v reflect.Value, typed bool) {
+	if typed {
+		g.w.Write(nilBytes)
+		return
+	}
+	g.w.Write(openParenBytes)
+	io.WriteString(g.w, g.typeString(v.Type()))
+	g.w.Write(closeParenBytes)
+	g.w.Write(openParenBytes)
+	g.w.Write(nilBytes)
+	g.w.Write(closeParenBytes)
+}
+
+// defaultLiteralType returns whether an untyped constant of kind defaults to
+// the type t, so no conversion is needed to write a value of type t.
+func defaultLiteralType(t reflect.Type, kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.Int, reflect.String:
+		return t.Name() == kind.String() && t.PkgPath() == ""
+	}
+	return false
+}
+
+// gosyntax is the main workhorse for writing a value as Go syntax.  It uses
+// the passed reflect value to figure out what kind of object we are dealing
+// with and writes it as a literal of its type.
+func (g *goSyntaxState) gosyntax(v reflect.Value) {
+	// Handle nil interfaces immediately.
+	kind := v.Kind()
+	if kind == reflect.Invalid {
+		g.w.Write(nilBytes)
+		return
+	}
+	typed := g.ignoreNextType
+	g.ignoreNextType = false
+
+	switch kind {
+	case reflect.Ptr:
+		g.goPtr(v, typed)
+		return
+
+	case reflect.Interface:
+		// The only time we should get here is for nil interfaces due to
+		// unpackValue calls.
+		g.goNil(v, typed)
+		return
+
+	case reflect.Slice, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		if v.IsNil() {
+			g.goNil(v, typed)
+			return
+		}
+		if kind == reflect.Chan || kind == reflect.Func || kind == reflect.UnsafePointer {
+			// There is no literal for these, so write their zero value
+			// and keep the address as a comment.
+			g.goNil(v, typed)
+			g.w.Write(openCommentBytes)
+			printHexPtr(g.w, v.Pointer())
+			g.w.Write(closeCommentBytes)
+			return
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			io.WriteString(g.w, g.typeString(v.Type()))
+			g.w.Write(openParenBytes)
+			switch {
+			case math.IsNaN(f):
+				io.WriteString(g.w, "math.NaN()")
+			case f > 0:
+				io.WriteString(g.w, "math.Inf(1)")
+			default:
+				io.WriteString(g.w, "math.Inf(-1)")
+			}
+			g.w.Write(closeParenBytes)
+			return
+		}
+	}
+
+	// Convert constants to their type unless it is inferred from the context
+	// or it is the default type of the constant.
+	convert := false
+	switch kind {
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.Struct:
+		// Composite literals always include their type.
+	default:
+		convert = !typed && !defaultLiteralType(v.Type(), kind)
+	}
+	if convert {
+		io.WriteString(g.w, g.typeString(v.Type()))
+		g.w.Write(openParenBytes)
+	}
+	if kind == reflect.Uintptr {
+		g.w.Write(hexPrefixBytes)
+		printUint(g.w, v.Uint(), 16)
+	} else {
+		printValue(g.w, g, v, kind, g.cs, nil)
+	}
+	if convert {
+		g.w.Write(closeParenBytes)
+	}
+}
+
+// element writes a single element of a composite literal on its own line.
+// The type of v is only written when it differs from the static type of the
+// element.
+func (g *goSyntaxState) element(v reflect.Value) {
+	g.ignoreNextType = true
+	g.gosyntax(g.unpackValue(v))
+}
+
+func (g *goSyntaxState) printArray(v reflect.Value) {
+	io.WriteString(g.w, g.typeString(v.Type()))
+
+	// Write byte slices holding text as a conversion from a string literal.
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		if buf, ok := byteSliceOf(&g.config, v); ok && utf8.Valid(buf) {
+			b := bufferGet()
+			b.SetBytes(strconv.AppendQuote(b.Bytes(), string(buf)))
+			g.w.Write(openParenBytes)
+			g.w.Write(b.Bytes())
+			g.w.Write(closeParenBytes)
+			bufferPut(b)
+			return
+		}
+	}
+
+	g.w.Write(openBraceBytes)
+	numEntries := v.Len()
+	if numEntries == 0 {
+		g.w.Write(closeBraceBytes)
+		return
+	}
+	g.w.Write(newlineBytes)
+	g.depth++
+	for i := 0; i < numEntries; i++ {
+		g.indent()
+		g.element(v.Index(i))
+		g.w.Write(commaNewlineBytes)
+	}
+	g.depth--
+	g.indent()
+	g.w.Write(closeBraceBytes)
+}
+
+func (g *goSyntaxState) printString(v reflect.Value) {
+	b := bufferGet()
+	defer bufferPut(b)
+	b.SetBytes(strconv.AppendQuote(b.Bytes(), v.String()))
+	g.w.Write(b.Bytes())
+}
+
+func (g *goSyntaxState) printMap(v reflect.Value) {
+	io.WriteString(g.w, g.typeString(v.Type()))
+	g.w.Write(openBraceBytes)
+	keys := v.MapKeys()
+	if len(keys) == 0 {
+		g.w.Write(closeBraceBytes)
+		return
+	}
+	if g.cs.SortKeys {
+		sortValues(keys, g.cs)
+	}
+	g.w.Write(newlineBytes)
+	g.depth++
+	for _, key := range keys {
+		g.indent()
+		if g.cs.redactsKey(key) {
+			g.w.Write(commentBytes)
+			g.element(key)
+			g.w.Write(colonSpaceBytes)
+			g.redacted(v.MapIndex(key))
+			continue
+		}
+		g.element(key)
+		g.w.Write(colonSpaceBytes)
+		g.element(v.MapIndex(key))
+		g.w.Write(commaNewlineBytes)
+	}
+	g.depth--
+	g.indent()
+	g.w.Write(closeBraceBytes)
+}
+
+func (g *goSyntaxState) printStruct(v reflect.Value) {
+	vt := v.Type()
+	io.WriteString(g.w, g.typeString(vt))
+	g.w.Write(openBraceBytes)
+	local := g.isLocal(vt)
+	wroteField := false
+	g.depth++
+	for i, o := range fieldOptionsOf(vt) {
+		vf := v.Field(i)
+		if o.skip || vf.IsZero() {
+			continue
+		}
+		if !wroteField {
+			g.w.Write(newlineBytes)
+			wroteField = true
+		}
+		g.indent()
+		vtf := vt.Field(i)
+
+		// Redacted values are left out and reported as a comment.
+		if g.cs.redactsField(o, vtf.Name) {
+			g.w.Write(commentBytes)
+			io.WriteString(g.w, vtf.Name)
+			g.w.Write(colonSpaceBytes)
+			g.redacted(vf)
+			continue
+		}
+
+		// Unexported fields of foreign packages can't be set in a
+		// composite literal, so report them as a comment instead.
+		if vtf.PkgPath != "" && !local {
+			g.w.Write(commentBytes)
+			io.WriteString(g.w, vtf.Name)
+			g.w.Write(colonSpaceBytes)
+			fmt.Fprintf(g.w, "%#v", valueFormatter{vf, g.cs})
+			g.w.Write(unexportedNoteBytes)
+			g.w.Write(newlineBytes)
+			continue
+		}
+
+		io.WriteString(g.w, vtf.Name)
+		g.w.Write(colonSpaceBytes)
+		g.element(vf)
+		g.w.Write(commaNewlineBytes)
+	}
+	g.depth--
+	if wroteField {
+		g.indent()
+	}
+	g.w.Write(closeBraceBytes)
+}
+
+// redacted writes the comment of a redacted struct field or map entry after
+// its name or key.
+func (g *goSyntaxState) redacted(v reflect.Value) {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	io.WriteString(g.w, g.typeString(v.Type()))
+	g.w.Write(spaceBytes)
+	printRedacted(g.w, v, nil)
+	g.w.Write(newlineBytes)
+}
+
+func (g *goSyntaxState) defaultFormat() string {
+	return "%#v"
+}
+
+// writeHelpers writes the declarations and assignments of the helper
+// variables for pointers which can't be written inline and returns whether
+// there were any.  They are all declared before they are assigned so they can
+// refer to each other in any order.
+func (g *goSyntaxState) writeHelpers(v reflect.Value) bool {
+	var helpers []reflect.Value
+	for _, p := range g.census.order {
+		if g.needsHelper(p) {
+			name := "p" + strconv.Itoa(len(helpers)+1)
+			g.helpers[pointerKey(p)] = name
+			helpers = append(helpers, p)
+		}
+	}
+	if len(helpers) == 0 {
+		return false
+	}
+
+	g.w.Write(funcOpenBytes)
+	io.WriteString(g.w, g.typeString(v.Type()))
+	g.w.Write(spaceBytes)
+	g.w.Write(openBraceNewlineBytes)
+	g.depth++
+	for _, p := range helpers {
+		g.indent()
+		io.WriteString(g.w, g.helpers[pointerKey(p)])
+		g.w.Write(helperDeclareBytes)
+		io.WriteString(g.w, g.typeString(p.Type().Elem()))
+		g.w.Write(closeParenBytes)
+		g.w.Write(newlineBytes)
+	}
+	for _, p := range helpers {
+		if p.Elem().IsZero() {
+			continue
+		}
+		g.indent()
+		g.w.Write(asteriskBytes)
+		io.WriteString(g.w, g.helpers[pointerKey(p)])
+		g.w.Write(helperAssignBytes)
+		g.element(p.Elem())
+		g.w.Write(newlineBytes)
+	}
+	g.indent()
+	g.w.Write(returnBytes)
+	return true
+}
+
+// fgosyntax is a helper function to consolidate the logic from the various
+// public methods which take varying writers and config states.
+func fgosyntax(cs *ConfigState, w io.Writer, a interface{}) {
+	g := &goSyntaxState{
+		w:       w,
+		config:  *cs,
+		census:  pointerCensus{counts: make(map[ptrKey]int)},
+		helpers: make(map[ptrKey]string),
+	}
+
+	// The results of the error and Stringer interfaces are not valid Go
+	// syntax, so they are never invoked.
+	g.config.DisableMethods = true
+	g.cs = &g.config
+
+	v := reflect.ValueOf(a)
+	g.census.collect(v)
+	if g.writeHelpers(v) {
+		g.ignoreNextType = true
+		g.gosyntax(v)
+		g.w.Write(newlineBytes)
+		g.depth--
+		g.w.Write(funcCloseBytes)
+		return
+	}
+	g.gosyntax(v)
+}
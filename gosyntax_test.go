@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew_test
+
+import (
+	"go/parser"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/spewerspew/spew"
+)
+
+// goSyntaxTests houses the tests to be performed against GoSyntax.
+var goSyntaxTests []renderTest
+
+// addGoSyntaxTest is a helper method to append the passed config, input and
+// desired result to goSyntaxTests.
+func addGoSyntaxTest(cs *spew.ConfigState, in interface{}, want string) {
+	goSyntaxTests = append(goSyntaxTests, renderTest{cs, in, want})
+}
+
+func addScalarGoSyntaxTests() {
+	cs := &spew.ConfigState{Indent: "\t"}
+	addGoSyntaxTest(cs, nil, "nil")
+	addGoSyntaxTest(cs, 1, "1")
+	addGoSyntaxTest(cs, int8(-1), "int8(-1)")
+	addGoSyntaxTest(cs, uint(7), "uint(7)")
+	addGoSyntaxTest(cs, 1.5, "float64(1.5)")
+	addGoSyntaxTest(cs, math.Inf(1), "float64(math.Inf(1))")
+	addGoSyntaxTest(cs, complex64(complex(1, 2)), "complex64((1+2i))")
+	addGoSyntaxTest(cs, true, "true")
+	addGoSyntaxTest(cs, "a\"b", `"a\"b"`)
+	addGoSyntaxTest(cs, stringer("s"), `spew_test.stringer("s")`)
+	addGoSyntaxTest(cs, uintptr(0x10), "uintptr(0x10)")
+	addGoSyntaxTest(cs, make(chan int), "(chan int)(nil) /* ")
+}
+
+func addContainerGoSyntaxTests() {
+	type goSyntaxStruct struct {
+		Name string
+		Any  interface{}
+		n    int
+	}
+	cs := &spew.ConfigState{Indent: "\t", SortKeys: true}
+	addGoSyntaxTest(cs, []int(nil), "([]int)(nil)")
+	addGoSyntaxTest(cs, []int{}, "[]int{}")
+	addGoSyntaxTest(cs, []int{1, 2}, "[]int{\n\t1,\n\t2,\n}")
+	addGoSyntaxTest(cs, []byte("hi"), `[]uint8("hi")`)
+	addGoSyntaxTest(cs, []byte{0xff}, "[]uint8{\n\t255,\n}")
+	addGoSyntaxTest(cs, [2]bool{true}, "[2]bool{\n\ttrue,\n\tfalse,\n}")
+	addGoSyntaxTest(cs, map[string]interface{}{"b": int16(2), "a": nil},
+		"map[string]interface {}{\n\t\"a\": nil,\n\t\"b\": int16(2),\n}")
+	addGoSyntaxTest(cs, goSyntaxStruct{}, "spew_test.goSyntaxStruct{}")
+	addGoSyntaxTest(cs, goSyntaxStruct{Name: "x", n: 2},
+		"spew_test.goSyntaxStruct{\n\tName: \"x\",\n\t// n: (int)2 (unexported)\n}")
+	addGoSyntaxTest(cs, omitted{A: 1, Skip: 2}, "spew_test.omitted{\n\tA: 1,\n}")
+	addGoSyntaxTest(&spew.ConfigState{Indent: "\t", GoSyntaxPackage: "github.com/spewerspew/spew_test"},
+		goSyntaxStruct{Name: "x", n: 2}, "goSyntaxStruct{\n\tName: \"x\",\n\tn: 2,\n}")
+	addGoSyntaxTest(cs, &goSyntaxStruct{Any: []string{"a"}},
+		"&spew_test.goSyntaxStruct{\n\tAny: []string{\n\t\t\"a\",\n\t},\n}")
+}
+
+func addPointerGoSyntaxTests() {
+	cs := &spew.ConfigState{Indent: "\t"}
+	shared := &node{Name: "s"}
+	i := 5
+	pi := &i
+	addGoSyntaxTest(cs, (*int)(nil), "(*int)(nil)")
+	addGoSyntaxTest(cs, nodeCycle(), "func() *spew_test.node {\n"+
+		"\tp1 := new(spew_test.node)\n"+
+		"\t*p1 = spew_test.node{\n"+
+		"\t\tName: \"a\",\n"+
+		"\t\tNext: &spew_test.node{\n"+
+		"\t\t\tName: \"b\",\n"+
+		"\t\t\tNext: p1,\n"+
+		"\t\t},\n"+
+		"\t}\n"+
+		"\treturn p1\n"+
+		"}()")
+	addGoSyntaxTest(cs, []*node{shared, shared}, "func() []*spew_test.node {\n"+
+		"\tp1 := new(spew_test.node)\n"+
+		"\t*p1 = spew_test.node{\n"+
+		"\t\tName: \"s\",\n"+
+		"\t}\n"+
+		"\treturn []*spew_test.node{\n"+
+		"\t\tp1,\n"+
+		"\t\tp1,\n"+
+		"\t}\n"+
+		"}()")
+	addGoSyntaxTest(cs, &pi, "func() **int {\n"+
+		"\tp1 := new(*int)\n"+
+		"\tp2 := new(int)\n"+
+		"\t*p1 = p2\n"+
+		"\t*p2 = 5\n"+
+		"\treturn p1\n"+
+		"}()")
+}
+
+func setupGoSyntaxTests() {
+	if len(goSyntaxTests) == 0 {
+		addScalarGoSyntaxTests()
+		addContainerGoSyntaxTests()
+		addPointerGoSyntaxTests()
+	}
+}
+
+// TestGoSyntax executes all of the tests described by goSyntaxTests and
+// ensures the output is a valid Go expression.
+func TestGoSyntax(t *testing.T) {
+	setupGoSyntaxTests()
+
+	t.Logf("Running %d tests", len(goSyntaxTests))
+	for i, test := range goSyntaxTests {
+		s := test.cs.GoSyntax(test.in)
+		if _, ok := test.in.(chan int); ok {
+			// Channels include their address as a comment.
+			if !strings.HasPrefix(s, test.want) {
+				t.Errorf("GoSyntax #%d\n got: %s want prefix: %s", i, s, test.want)
+			}
+		} else if s != test.want {
+			t.Errorf("GoSyntax #%d\n got: %s want: %s", i, s, test.want)
+			continue
+		}
+		if _, err := parser.ParseExpr(s); err != nil {
+			t.Errorf("GoSyntax #%d invalid expression %q: %v", i, s, err)
+		}
+	}
+}
@@ -5,38 +5,137 @@
 package spew
 
 import (
-	"encoding/hex"
 	"io"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
+// HexDumpText selects the right-hand column of the hexdumps of byte arrays and
+// slices.
+type HexDumpText int
+
+const (
+	// HexDumpASCII shows printable ASCII characters and a dot for every
+	// other byte.
+	HexDumpASCII HexDumpText = iota
+
+	// HexDumpUTF8 shows printable UTF-8 encoded runes at the position of
+	// their first byte followed by a space for every continuation byte, and
+	// a dot for every other byte which isn't printable ASCII.
+	HexDumpUTF8
+
+	// HexDumpNoText leaves out the right-hand column.
+	HexDumpNoText
+)
+
+// HexDumpOptions controls the layout of the hexdumps of byte arrays and slices.
+// The zero value matches the output of hexdump -C.
+type HexDumpOptions struct {
+	// BytesPerLine specifies the number of bytes shown on each line.  The
+	// default, 0, shows 16 bytes.
+	BytesPerLine int
+
+	// GroupSize specifies the number of bytes after which an additional
+	// space separates the bytes of a line.  The default, 0, groups 8 bytes
+	// and a negative value disables grouping.
+	GroupSize int
+
+	// Uppercase specifies whether hex digits are written in uppercase.
+	Uppercase bool
+
+	// OffsetWidth specifies the minimum number of hex digits of the offset
+	// at the start of each line.  All offsets of a hexdump are widened to
+	// fit the largest one.  The default, 0, writes 8 digits.
+	OffsetWidth int
+
+	// BaseOffset is added to the offsets at the start of each line, for
+	// example to show the offsets of the bytes in a file.
+	BaseOffset uint64
+
+	// Text selects the right-hand column.  The default shows ASCII.
+	Text HexDumpText
+}
+
+// hexDigitsUpper is used to map a decimal value to an uppercase hex digit.
+const hexDigitsUpper = "0123456789ABCDEF"
+
 var hexDumperPool = sync.Pool{
 	New: func() interface{} {
 		return new(hexDumper)
 	},
 }
 
-func hexDump(w io.Writer, data []byte, indent string) {
+// hexDump writes the hexdump of data to w with the layout of opts.  Every line
+// is preceded by indent and offsets start at offset past opts.BaseOffset.
+func hexDump(w io.Writer, data []byte, indent string, offset uint, opts *HexDumpOptions) error {
 	h := hexDumperPool.Get().(*hexDumper)
-	h.w = w
-	h.used = 0
-	h.n = 0
-	h.closed = false
-	h.indent = indent
+	h.Reset(w, indent, opts)
+	h.n += uint64(offset)
 
-	h.Write(data)
-	h.Close()
+	// Widen the offsets of all lines to fit the offset of the last one.
+	if len(data) > 0 {
+		last := h.n + uint64(len(data)-1)
+		width := 1
+		for v := last >> 4; v != 0; v >>= 4 {
+			width++
+		}
+		if width > h.offsetWidth {
+			h.offsetWidth = width
+		}
+	}
+
+	_, err := h.Write(data)
+	if cerr := h.Close(); err == nil {
+		err = cerr
+	}
+	h.w = nil
 	hexDumperPool.Put(h)
+	return err
 }
 
 type hexDumper struct {
-	w          io.Writer
-	rightChars [18]byte
-	buf        [14]byte
-	used       int  // number of bytes in the current line
-	n          uint // number of bytes, total
-	closed     bool
-	indent     string
+	w            io.Writer
+	line         []byte // the current line
+	text         []byte // the right-hand column of the current line
+	used         int    // number of bytes in the current line
+	n            uint64 // offset of the next byte
+	skip         int    // continuation bytes of the last rune in the text
+	closed       bool
+	indent       string
+	digits       string
+	bytesPerLine int
+	groupSize    int
+	offsetWidth  int
+	textMode     HexDumpText
+}
+
+// Reset prepares h to write a new hexdump to w with the layout of opts.
+func (h *hexDumper) Reset(w io.Writer, indent string, opts *HexDumpOptions) {
+	*h = hexDumper{
+		w:            w,
+		line:         h.line[:0],
+		text:         h.text[:0],
+		n:            opts.BaseOffset,
+		indent:       indent,
+		digits:       hexDigits,
+		bytesPerLine: 16,
+		groupSize:    8,
+		offsetWidth:  8,
+		textMode:     opts.Text,
+	}
+	if opts.Uppercase {
+		h.digits = hexDigitsUpper
+	}
+	if opts.BytesPerLine > 0 {
+		h.bytesPerLine = opts.BytesPerLine
+	}
+	if opts.GroupSize != 0 {
+		h.groupSize = opts.GroupSize
+	}
+	if opts.OffsetWidth > 0 {
+		h.offsetWidth = opts.OffsetWidth
+	}
 }
 
 func toChar(b byte) byte {
@@ -56,59 +155,76 @@ func (h *hexDumper) Write(data []byte) (n int, err error) {
 	// ^ offset                          ^ extra space              ^ ASCII of line.
 	for i := range data {
 		if h.used == 0 {
-			// Before the beginning of a line we print the indent.
-			_, err = io.WriteString(h.w, h.indent)
-			if err != nil {
-				return n, err
+			// At the beginning of a line we print the indent and the
+			// current offset in hex, padded to the offset width.
+			h.line = append(h.line[:0], h.indent...)
+			for j := h.offsetWidth - 1; j >= 0; j-- {
+				h.line = append(h.line, h.digits[h.n>>(4*uint(j))&0x0f])
 			}
-
-			// At the beginning of a line we print the current
-			// offset in hex.
-			h.buf[0] = byte(h.n >> 24)
-			h.buf[1] = byte(h.n >> 16)
-			h.buf[2] = byte(h.n >> 8)
-			h.buf[3] = byte(h.n)
-			hex.Encode(h.buf[4:], h.buf[:4])
-			h.buf[12] = ' '
-			h.buf[13] = ' '
-			_, err = h.w.Write(h.buf[4:])
-			if err != nil {
-				return n, err
-			}
-		}
-		hex.Encode(h.buf[:], data[i:i+1])
-		h.buf[2] = ' '
-		l := 3
-		if h.used == 7 {
-			// There's an additional space after the 8th byte.
-			h.buf[3] = ' '
-			l = 4
-		} else if h.used == 15 {
-			// At the end of the line there's an extra space and
-			// the bar for the right column.
-			h.buf[3] = ' '
-			h.buf[4] = '|'
-			l = 5
-		}
-		_, err = h.w.Write(h.buf[:l])
-		if err != nil {
-			return n, err
+			h.line = append(h.line, ' ', ' ')
+			h.text = h.text[:0]
 		}
+		b := data[i]
+		h.line = append(h.line, h.digits[b>>4], h.digits[b&0x0f], ' ')
+		h.appendSeparator()
+		h.appendText(data, i)
 		n++
-		h.rightChars[h.used] = toChar(data[i])
-		h.used++
 		h.n++
-		if h.used == 16 {
-			h.rightChars[16] = '|'
-			h.rightChars[17] = '\n'
-			_, err = h.w.Write(h.rightChars[:])
-			if err != nil {
+		if h.used == h.bytesPerLine {
+			if err = h.flush(); err != nil {
 				return n, err
 			}
-			h.used = 0
 		}
 	}
-	return n, err
+	return n, nil
+}
+
+// appendSeparator counts the byte appended to the current line and appends
+// the additional space after the last byte of a group unless it ends the line.
+func (h *hexDumper) appendSeparator() {
+	h.used++
+	if h.groupSize > 0 && h.used%h.groupSize == 0 && h.used < h.bytesPerLine {
+		h.line = append(h.line, ' ')
+	}
+}
+
+// appendText appends the right-hand column character of data[i] to the text
+// of the current line.  Runes are only decoded when they are complete within
+// data.
+func (h *hexDumper) appendText(data []byte, i int) {
+	switch {
+	case h.textMode == HexDumpNoText:
+		return
+	case h.skip > 0:
+		h.text = append(h.text, ' ')
+		h.skip--
+		return
+	case h.textMode == HexDumpUTF8 && data[i] >= utf8.RuneSelf:
+		r, size := utf8.DecodeRune(data[i:])
+		if r != utf8.RuneError && unicode.IsPrint(r) {
+			var buf [utf8.UTFMax]byte
+			h.text = append(h.text, buf[:utf8.EncodeRune(buf[:], r)]...)
+			h.skip = size - 1
+			return
+		}
+	}
+	h.text = append(h.text, toChar(data[i]))
+}
+
+// flush writes the current line followed by its right-hand column.
+func (h *hexDumper) flush() error {
+	if h.textMode != HexDumpNoText {
+		h.line = append(h.line, ' ', '|')
+		h.line = append(h.line, h.text...)
+		h.line = append(h.line, '|')
+	} else {
+		// Drop the space after the last byte.
+		h.line = h.line[:len(h.line)-1]
+	}
+	h.line = append(h.line, '\n')
+	h.used = 0
+	_, err := h.w.Write(h.line)
+	return err
 }
 
 func (h *hexDumper) Close() (err error) {
@@ -120,27 +236,11 @@ func (h *hexDumper) Close() (err error) {
 	if h.used == 0 {
 		return
 	}
-	h.buf[0] = ' '
-	h.buf[1] = ' '
-	h.buf[2] = ' '
-	h.buf[3] = ' '
-	h.buf[4] = '|'
-	nBytes := h.used
-	for h.used < 16 {
-		l := 3
-		if h.used == 7 {
-			l = 4
-		} else if h.used == 15 {
-			l = 5
+	if h.textMode != HexDumpNoText {
+		for h.used < h.bytesPerLine {
+			h.line = append(h.line, ' ', ' ', ' ')
+			h.appendSeparator()
 		}
-		_, err = h.w.Write(h.buf[:l])
-		if err != nil {
-			return
-		}
-		h.used++
 	}
-	h.rightChars[nBytes] = '|'
-	h.rightChars[nBytes+1] = '\n'
-	_, err = h.w.Write(h.rightChars[:nBytes+2])
-	return err
+	return h.flush()
 }
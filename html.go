@@ -0,0 +1,547 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// htmlHeader is written before the dumped values of an HTML document.  The
+// document is self-contained, so the style sheet is embedded.
+const htmlHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>spew</title>
+<style>
+.spew { font: 13px/1.4 ui-monospace, Menlo, Consolas, monospace; margin: 0 0 1em; }
+.spew summary, .spew .v { white-space: pre; }
+.spew summary { cursor: pointer; }
+.spew details:not([open]) > summary::after { content: "\2026}"; }
+.spew .c { margin-left: 0.4em; padding-left: 1.6ch; border-left: 1px dotted #ccc; }
+.spew .x { margin: 0; }
+.spew .t { color: #268bd2; }
+.spew .l { color: #93a1a1; }
+.spew .p { color: #6c71c4; }
+.spew .f { color: #b58900; }
+.spew .s { color: #2aa198; }
+.spew .m { color: #dc322f; }
+.spew :target > summary, .spew .v:target { background: #fdf6c3; }
+</style>
+</head>
+<body>
+`
+
+// htmlFooter is written after the dumped values of an HTML document.
+const htmlFooter = `</body>
+</html>
+`
+
+// Some constants in the form of bytes to avoid string overhead when writing
+// HTML.
+var (
+	htmlValueOpenBytes    = []byte(`<div class="spew">` + "\n")
+	htmlValueCloseBytes   = []byte("</div>\n")
+	htmlTypeSpanBytes     = []byte(`<span class="t">`)
+	htmlTypeOpenBytes     = []byte(`<span class="t">(`)
+	htmlLenOpenBytes      = []byte(`<span class="l">(`)
+	htmlPtrOpenBytes      = []byte(`<span class="p">(`)
+	htmlFieldOpenBytes    = []byte(`<span class="f">`)
+	htmlStringOpenBytes   = []byte(`<span class="s">`)
+	htmlMaxDepthBytes     = []byte(`{<span class="m">&lt;max depth reached&gt;</span>}`)
+	htmlParenCloseBytes   = []byte(")</span>")
+	htmlSpanCloseBytes    = []byte("</span>")
+	htmlDivOpenBytes      = []byte(`<div class="v"`)
+	htmlDivCloseBytes     = []byte("</div>\n")
+	htmlDetailsOpenBytes  = []byte("<details open")
+	htmlSummaryOpenBytes  = []byte("><summary>")
+	htmlSummaryCloseBytes = []byte("{</summary>\n<div class=\"c\">\n")
+	htmlDetailsBodyBytes  = []byte("</div>}")
+	htmlDetailsCloseBytes = []byte("</details>\n")
+	htmlHexOpenBytes      = []byte(`<pre class="x">`)
+	htmlHexCloseBytes     = []byte("</pre>\n")
+	htmlIDBytes           = []byte(` id="`)
+	htmlLinkOpenBytes     = []byte(`<a href="#`)
+	htmlLinkCloseBytes    = []byte("</a>")
+	htmlQuoteBytes        = []byte(`"`)
+	htmlEmptyBytes        = []byte("{}")
+	htmlTagEndBytes       = []byte(">")
+)
+
+// htmlEscaper is an io.Writer which escapes the text written to it for use
+// in HTML documents before passing it to w.
+type htmlEscaper struct {
+	w io.Writer
+}
+
+// Write escapes p and writes it to the underlying writer.
+func (e *htmlEscaper) Write(p []byte) (n int, err error) {
+	last := 0
+	for i, c := range p {
+		var entity string
+		switch c {
+		case '&':
+			entity = "&amp;"
+		case '<':
+			entity = "&lt;"
+		case '>':
+			entity = "&gt;"
+		case '"':
+			entity = "&#34;"
+		case '\'':
+			entity = "&#39;"
+		default:
+			continue
+		}
+		if _, err = e.w.Write(p[last:i]); err != nil {
+			return last, err
+		}
+		if _, err = io.WriteString(e.w, entity); err != nil {
+			return i, err
+		}
+		last = i + 1
+	}
+	if _, err = e.w.Write(p[last:]); err != nil {
+		return last, err
+	}
+	return len(p), nil
+}
+
+// htmlState contains information about the state of an HTML dump operation.
+//
+// The markup of the line which is currently being built, such as a field
+// name followed by the type of the value, is collected in head.  Values which
+// aren't collections are written as a single line once complete, while
+// collections open a collapsible node with the line as its summary.
+type htmlState struct {
+	w              io.Writer
+	depth          int
+	ignoreNextType bool
+	ci             *cycleInfo
+	cs             *ConfigState
+	head           bytes.Buffer
+	esc            htmlEscaper
+	out            htmlEscaper
+	tail           string
+	id             string
+	pending        bool
+	ids            map[uintptr]string
+	numIDs         int
+}
+
+// unpackValue returns values inside of non-nil interfaces when possible.
+func (h *htmlState) unpackValue(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// writeID writes the id attribute of the element which is opened next, if
+// any.
+func (h *htmlState) writeID() {
+	if h.id == "" {
+		return
+	}
+	h.w.Write(htmlIDBytes)
+	io.WriteString(h.w, h.id)
+	h.w.Write(htmlQuoteBytes)
+	h.id = ""
+}
+
+// flush writes the current line as a value which can't be collapsed.
+func (h *htmlState) flush() {
+	h.w.Write(htmlDivOpenBytes)
+	h.writeID()
+	h.w.Write(htmlTagEndBytes)
+	h.w.Write(h.head.Bytes())
+	io.WriteString(h.w, h.tail)
+	h.w.Write(htmlDivCloseBytes)
+	h.head.Reset()
+	h.tail = ""
+	h.pending = false
+}
+
+// open starts a collapsible node with the current line as its summary and
+// returns the text which has to be written after the closing brace.
+func (h *htmlState) open() (tail string) {
+	h.w.Write(htmlDetailsOpenBytes)
+	h.writeID()
+	h.w.Write(htmlSummaryOpenBytes)
+	h.w.Write(h.head.Bytes())
+	h.w.Write(htmlSummaryCloseBytes)
+	tail = h.tail
+	h.head.Reset()
+	h.tail = ""
+	h.pending = false
+	return tail
+}
+
+// close ends a collapsible node started by open.
+func (h *htmlState) close(tail string) {
+	h.w.Write(htmlDetailsBodyBytes)
+	io.WriteString(h.w, tail)
+	h.w.Write(htmlDetailsCloseBytes)
+}
+
+// writeType writes the type of a value to the current line.
+func (h *htmlState) writeType(indirects int, typ reflect.Type) {
+	h.head.Write(htmlTypeOpenBytes)
+	for i := 0; i < indirects; i++ {
+		h.head.Write(asteriskBytes)
+	}
+	io.WriteString(&h.esc, typ.String())
+	h.head.Write(htmlParenCloseBytes)
+}
+
+// htmlPtr handles formatting of pointers by indirecting them as necessary.
+// The first node of every pointer gets an id, so circular references can
+// link back to it.
+func (h *htmlState) htmlPtr(v reflect.Value) {
+	// Figure out how many levels of indirection there are by dereferencing
+	// pointers and unpacking interfaces down the chain while detecting circular
+	// references.
+	ve := derefPtr(v, h.depth, h.ci)
+
+	// Display type information.
+	h.writeType(h.ci.indirects, ve.Type())
+
+	// Display pointer information.
+	if !h.cs.DisablePointerAddresses && len(h.ci.pointerChain) > 0 {
+		h.head.Write(htmlPtrOpenBytes)
+		for i, addr := range h.ci.pointerChain {
+			if i > 0 {
+				h.esc.Write(pointerChainBytes)
+			}
+			printHexPtr(&h.head, addr)
+		}
+		h.head.Write(htmlParenCloseBytes)
+	}
+
+	// Display dereferenced value.
+	h.head.Write(openParenBytes)
+	switch {
+	case h.ci.nilFound:
+		h.esc.Write(nilAngleBytes)
+		h.head.Write(closeParenBytes)
+		h.flush()
+
+	case h.ci.cycleFound:
+		addr := h.ci.pointerChain[len(h.ci.pointerChain)-1]
+		if id, ok := h.ids[addr]; ok {
+			h.head.Write(htmlLinkOpenBytes)
+			io.WriteString(&h.head, id)
+			h.head.Write(htmlQuoteBytes)
+			h.head.Write(htmlTagEndBytes)
+			h.esc.Write(circularBytes)
+			h.head.Write(htmlLinkCloseBytes)
+		} else {
+			h.esc.Write(circularBytes)
+		}
+		h.head.Write(closeParenBytes)
+		h.flush()
+
+	default:
+		h.numIDs++
+		h.id = "n" + strconv.Itoa(h.numIDs)
+		for _, addr := range h.ci.pointerChain {
+			if _, ok := h.ids[addr]; !ok {
+				h.ids[addr] = h.id
+			}
+		}
+		h.tail = ")" + h.tail
+		h.ignoreNextType = true
+		h.dump(ve)
+	}
+}
+
+// writeHeader writes the type, length and capacity of v to the current line.
+func (h *htmlState) writeHeader(v reflect.Value, kind reflect.Kind) {
+	// Print type information unless already handled elsewhere.
+	if !h.ignoreNextType {
+		h.writeType(0, v.Type())
+		h.head.Write(spaceBytes)
+	}
+	h.ignoreNextType = false
+
+	// Display length and capacity if the built-in len and cap functions
+	// work with the value's kind and the len/cap itself is non-zero.
+	valueLen, valueCap := 0, 0
+	switch kind {
+	case reflect.Array, reflect.Slice, reflect.Chan:
+		valueLen, valueCap = v.Len(), v.Cap()
+	case reflect.Map, reflect.String:
+		valueLen = v.Len()
+	}
+	if valueLen != 0 || !h.cs.DisableCapacities && valueCap != 0 {
+		h.head.Write(htmlLenOpenBytes)
+		if valueLen != 0 {
+			h.head.Write(lenEqualsBytes)
+			printInt(&h.head, int64(valueLen), 10)
+		}
+		if !h.cs.DisableCapacities && valueCap != 0 {
+			if valueLen != 0 {
+				h.head.Write(spaceBytes)
+			}
+			h.head.Write(capEqualsBytes)
+			printInt(&h.head, int64(valueCap), 10)
+		}
+		h.head.Write(htmlParenCloseBytes)
+		h.head.Write(spaceBytes)
+	}
+}
+
+// dump is the main workhorse for writing a value as HTML.  It uses the passed
+// reflect value to figure out what kind of object we are dealing with and
+// formats it the same way as Dump.  It is a recursive function, however
+// circular data structures are detected and handled properly.
+func (h *htmlState) dump(v reflect.Value) {
+	// Handle invalid reflect values immediately.
+	kind := v.Kind()
+	if kind == reflect.Invalid {
+		h.esc.Write(invalidAngleBytes)
+		h.flush()
+		return
+	}
+
+	// Handle pointers specially.
+	if kind == reflect.Ptr {
+		h.htmlPtr(v)
+		return
+	}
+
+	h.writeHeader(v, kind)
+
+	// The collection printers open a node for the current line, any other
+	// value is written as a single line.
+	h.pending = true
+	printValue(&h.esc, h, v, kind, h.cs, nil)
+	if h.pending {
+		h.flush()
+	}
+}
+
+// dumpKey writes the map key v to the current line.  Keys which are
+// collections or pointers are written inline like the %v verb of the custom
+// formatter does.
+func (h *htmlState) dumpKey(v reflect.Value) {
+	kind := v.Kind()
+	switch kind {
+	case reflect.Invalid:
+		h.esc.Write(invalidAngleBytes)
+
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.Struct, reflect.Ptr:
+		h.writeType(0, v.Type())
+		h.head.Write(spaceBytes)
+		fmt.Fprintf(&h.esc, "%v", valueFormatter{v, h.cs})
+
+	default:
+		h.writeHeader(v, kind)
+		printValue(&h.esc, h, v, kind, h.cs, nil)
+	}
+}
+
+// dumpRedacted writes the type of v followed by a marker instead of its value
+// for redacted struct fields and map entries.
+func (h *htmlState) dumpRedacted(v reflect.Value) {
+	h.writeType(0, v.Type())
+	h.head.Write(spaceBytes)
+	printRedacted(&h.esc, v, nil)
+	h.flush()
+}
+
+func (h *htmlState) printArray(v reflect.Value) {
+	numEntries := v.Len()
+	h.depth++
+	defer func() { h.depth-- }()
+	switch {
+	case numEntries == 0:
+		h.head.Write(htmlEmptyBytes)
+		return
+
+	case (h.cs.MaxDepth != 0) && (h.depth > h.cs.MaxDepth):
+		h.head.Write(htmlMaxDepthBytes)
+		return
+	}
+
+	tail := h.open()
+
+	// Byte arrays and slices are dumped in hexdump -C fashion.
+	if buf, ok := byteSliceOf(h.cs, v); ok {
+		h.w.Write(htmlHexOpenBytes)
+		hexDump(&h.out, buf, "", 0, &h.cs.HexDump)
+		h.w.Write(htmlHexCloseBytes)
+		h.close(tail)
+		return
+	}
+
+	for i := 0; i < numEntries; i++ {
+		if i < (numEntries - 1) {
+			h.tail = ","
+		}
+		h.dump(h.unpackValue(v.Index(i)))
+	}
+	h.close(tail)
+}
+
+func (h *htmlState) printString(v reflect.Value) {
+	h.head.Write(htmlStringOpenBytes)
+	b := bufferGet()
+	defer bufferPut(b)
+	b.SetBytes(strconv.AppendQuote(b.Bytes(), v.String()))
+	h.esc.Write(b.Bytes())
+	h.head.Write(htmlSpanCloseBytes)
+}
+
+func (h *htmlState) printMap(v reflect.Value) {
+	numEntries := v.Len()
+	h.depth++
+	defer func() { h.depth-- }()
+	switch {
+	case numEntries == 0:
+		h.head.Write(htmlEmptyBytes)
+		return
+
+	case (h.cs.MaxDepth != 0) && (h.depth > h.cs.MaxDepth):
+		h.head.Write(htmlMaxDepthBytes)
+		return
+	}
+
+	tail := h.open()
+	keys := v.MapKeys()
+	if h.cs.SortKeys {
+		sortValues(keys, h.cs)
+	}
+	for i, key := range keys {
+		h.dumpKey(h.unpackValue(key))
+		h.head.Write(colonSpaceBytes)
+		if i < (numEntries - 1) {
+			h.tail = ","
+		}
+		if h.cs.redactsKey(key) {
+			h.dumpRedacted(h.unpackValue(v.MapIndex(key)))
+			continue
+		}
+		h.dump(h.unpackValue(v.MapIndex(key)))
+	}
+	h.close(tail)
+}
+
+func (h *htmlState) printStruct(v reflect.Value) {
+	vt := v.Type()
+	opts := fieldOptionsOf(vt)
+	numFields := fieldsLeft(v, opts, 0)
+	h.depth++
+	defer func() { h.depth-- }()
+	switch {
+	case numFields == 0:
+		h.head.Write(htmlEmptyBytes)
+		return
+
+	case (h.cs.MaxDepth != 0) && (h.depth > h.cs.MaxDepth):
+		h.head.Write(htmlMaxDepthBytes)
+		return
+	}
+
+	tail := h.open()
+	for i, o := range opts {
+		if o.skip || o.omitEmpty && v.Field(i).IsZero() {
+			continue
+		}
+		name := vt.Field(i).Name
+		h.head.Write(htmlFieldOpenBytes)
+		io.WriteString(&h.head, name)
+		h.head.Write(htmlSpanCloseBytes)
+		h.head.Write(colonSpaceBytes)
+		if fieldsLeft(v, opts, i+1) > 0 {
+			h.tail = ","
+		}
+		if h.cs.redactsField(o, name) {
+			h.dumpRedacted(h.unpackValue(v.Field(i)))
+			continue
+		}
+		h.dump(h.unpackValue(v.Field(i)))
+	}
+	h.close(tail)
+}
+
+func (h *htmlState) defaultFormat() string {
+	return "%v"
+}
+
+// fhtml is a helper function to consolidate the logic from the various public
+// methods which take varying writers and config states.
+func fhtml(cs *ConfigState, w io.Writer, a ...interface{}) {
+	h := htmlStatePool.Get().(*htmlState)
+	defer htmlStatePut(h)
+	h.w = w
+	h.cs = cs
+	h.ci = cycleInfoGet()
+	h.esc.w = &h.head
+	h.out.w = w
+	h.ids = make(map[uintptr]string)
+
+	io.WriteString(w, htmlHeader)
+	for _, arg := range a {
+		h.ci.resetPointers()
+		for k := range h.ids {
+			delete(h.ids, k)
+		}
+		h.depth = 0
+		h.ignoreNextType = false
+
+		w.Write(htmlValueOpenBytes)
+		if arg == nil {
+			h.head.Write(htmlTypeSpanBytes)
+			h.head.Write(interfaceBytes)
+			h.head.Write(htmlSpanCloseBytes)
+			h.head.Write(spaceBytes)
+			h.esc.Write(nilAngleBytes)
+			h.flush()
+		} else {
+			h.dump(reflect.ValueOf(arg))
+		}
+		w.Write(htmlValueCloseBytes)
+	}
+	io.WriteString(w, htmlFooter)
+}
+
+var htmlStatePool = sync.Pool{New: func() interface{} {
+	return new(htmlState)
+}}
+
+func htmlStatePut(h *htmlState) {
+	cycleInfoPut(h.ci)
+	h.w = nil
+	h.ci = nil
+	h.cs = nil
+	h.head.Reset()
+	h.esc.w = nil
+	h.out.w = nil
+	h.tail = ""
+	h.id = ""
+	h.pending = false
+	h.ids = nil
+	h.numIDs = 0
+	htmlStatePool.Put(h)
+}
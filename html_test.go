@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spewerspew/spew"
+)
+
+// htmlTests houses the tests to be performed against Fhtml.
+var htmlTests []renderTest
+
+// addHTMLTest is a helper method to append the passed config, input and
+// desired result to htmlTests.
+func addHTMLTest(cs *spew.ConfigState, in interface{}, want string) {
+	htmlTests = append(htmlTests, renderTest{cs, in, want})
+}
+
+// htmlBody returns the dumped values of the HTML document s.
+func htmlBody(t *testing.T, s string) string {
+	start := strings.Index(s, "<body>\n")
+	end := strings.Index(s, "</body>\n")
+	if start < 0 || end < start {
+		t.Fatalf("HTML document without body: %s", s)
+	}
+	return s[start+len("<body>\n") : end]
+}
+
+func addScalarHTMLTests() {
+	cs := &spew.ConfigState{}
+	addHTMLTest(cs, nil, `<div class="v"><span class="t">(interface {})</span> &lt;nil&gt;</div>`+"\n")
+	addHTMLTest(cs, 5, `<div class="v"><span class="t">(int)</span> 5</div>`+"\n")
+	addHTMLTest(cs, "<&>", `<div class="v"><span class="t">(string)</span> <span class="l">(len=3)</span> `+
+		`<span class="s">&#34;&lt;&amp;&gt;&#34;</span></div>`+"\n")
+}
+
+func addContainerHTMLTests() {
+	type htmlStruct struct {
+		A int
+		b string
+	}
+	cs := &spew.ConfigState{SortKeys: true}
+	addHTMLTest(cs, []int{}, `<div class="v"><span class="t">([]int)</span> {}</div>`+"\n")
+	addHTMLTest(cs, []int{1, 2}, `<details open><summary><span class="t">([]int)</span> <span class="l">(len=2 cap=2)</span> {</summary>`+"\n"+
+		`<div class="c">`+"\n"+
+		`<div class="v"><span class="t">(int)</span> 1,</div>`+"\n"+
+		`<div class="v"><span class="t">(int)</span> 2</div>`+"\n"+
+		`</div>}</details>`+"\n")
+	addHTMLTest(cs, htmlStruct{1, "x"}, `<details open><summary><span class="t">(spew_test.htmlStruct)</span> {</summary>`+"\n"+
+		`<div class="c">`+"\n"+
+		`<div class="v"><span class="f">A</span>: <span class="t">(int)</span> 1,</div>`+"\n"+
+		`<div class="v"><span class="f">b</span>: <span class="t">(string)</span> <span class="l">(len=1)</span> <span class="s">&#34;x&#34;</span></div>`+"\n"+
+		`</div>}</details>`+"\n")
+	addHTMLTest(cs, omitted{A: 1, Skip: 2}, `<details open><summary><span class="t">(spew_test.omitted)</span> {</summary>`+"\n"+
+		`<div class="c">`+"\n"+
+		`<div class="v"><span class="f">A</span>: <span class="t">(int)</span> 1</div>`+"\n"+
+		`</div>}</details>`+"\n")
+	addHTMLTest(cs, map[string]int{"a": 1}, `<details open><summary><span class="t">(map[string]int)</span> <span class="l">(len=1)</span> {</summary>`+"\n"+
+		`<div class="c">`+"\n"+
+		`<div class="v"><span class="t">(string)</span> <span class="l">(len=1)</span> <span class="s">&#34;a&#34;</span>: <span class="t">(int)</span> 1</div>`+"\n"+
+		`</div>}</details>`+"\n")
+	addHTMLTest(cs, []byte("<a>"), `<details open><summary><span class="t">([]uint8)</span> <span class="l">(len=3 cap=3)</span> {</summary>`+"\n"+
+		`<div class="c">`+"\n"+
+		`<pre class="x">00000000  3c 61 3e                                          |&lt;a&gt;|`+"\n"+
+		`</pre>`+"\n"+
+		`</div>}</details>`+"\n")
+	addHTMLTest(&spew.ConfigState{MaxDepth: 1}, [][]int{{1}}, `<details open><summary><span class="t">([][]int)</span> <span class="l">(len=1 cap=1)</span> {</summary>`+"\n"+
+		`<div class="c">`+"\n"+
+		`<div class="v"><span class="t">([]int)</span> <span class="l">(len=1 cap=1)</span> {<span class="m">&lt;max depth reached&gt;</span>}</div>`+"\n"+
+		`</div>}</details>`+"\n")
+}
+
+func addPointerHTMLTests() {
+	addHTMLTest(&spew.ConfigState{DisablePointerAddresses: true}, nodeCycle(),
+		`<details open id="n1"><summary><span class="t">(*spew_test.node)</span>({</summary>`+"\n"+
+			`<div class="c">`+"\n"+
+			`<div class="v"><span class="f">Name</span>: <span class="t">(string)</span> <span class="l">(len=1)</span> <span class="s">&#34;a&#34;</span>,</div>`+"\n"+
+			`<details open id="n2"><summary><span class="f">Next</span>: <span class="t">(*spew_test.node)</span>({</summary>`+"\n"+
+			`<div class="c">`+"\n"+
+			`<div class="v"><span class="f">Name</span>: <span class="t">(string)</span> <span class="l">(len=1)</span> <span class="s">&#34;b&#34;</span>,</div>`+"\n"+
+			`<div class="v"><span class="f">Next</span>: <span class="t">(*spew_test.node)</span>(<a href="#n1">&lt;already shown&gt;</a>)</div>`+"\n"+
+			`</div>})</details>`+"\n"+
+			`</div>})</details>`+"\n")
+}
+
+func addMethodHTMLTests() {
+	addHTMLTest(&spew.ConfigState{}, stringer("<x>"),
+		`<div class="v"><span class="t">(spew_test.stringer)</span> <span class="l">(len=3)</span> stringer &lt;x&gt;</div>`+"\n")
+}
+
+func setupHTMLTests() {
+	if len(htmlTests) == 0 {
+		addScalarHTMLTests()
+		addContainerHTMLTests()
+		addPointerHTMLTests()
+		addMethodHTMLTests()
+	}
+}
+
+// TestHTML executes all of the tests described by htmlTests.
+func TestHTML(t *testing.T) {
+	setupHTMLTests()
+
+	t.Logf("Running %d tests", len(htmlTests))
+	for i, test := range htmlTests {
+		buf := new(bytes.Buffer)
+		test.cs.Fhtml(buf, test.in)
+		s := htmlBody(t, buf.String())
+		want := `<div class="spew">` + "\n" + test.want + "</div>\n"
+		if s != want {
+			t.Errorf("HTML #%d\n got: %s want: %s", i, s, want)
+		}
+	}
+}
+
+// TestHTMLDocument ensures Fhtml writes a well-formed document in which every
+// link refers to an element of the document.
+func TestHTMLDocument(t *testing.T) {
+	buf := new(bytes.Buffer)
+	spew.Fhtml(buf, nodeCycle(), map[string][]byte{"a": {1, 2}}, nil)
+
+	d := xml.NewDecoder(buf)
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	ids := make(map[string]bool)
+	var links []string
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("HTML document malformed: %v", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			depth++
+			for _, attr := range tok.Attr {
+				switch attr.Name.Local {
+				case "id":
+					ids[attr.Value] = true
+				case "href":
+					links = append(links, strings.TrimPrefix(attr.Value, "#"))
+				}
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if depth != 0 {
+		t.Errorf("HTML document has %d unclosed elements", depth)
+	}
+	if len(links) != 1 {
+		t.Errorf("HTML document has %d links, want 1", len(links))
+	}
+	for _, link := range links {
+		if !ids[link] {
+			t.Errorf("HTML document links to missing id %q", link)
+		}
+	}
+}
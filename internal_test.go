@@ -24,7 +24,9 @@ package spew
 
 import (
 	"bytes"
+	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -82,3 +84,57 @@ func TestInvalidReflectValue(t *testing.T) {
 func SortValues(values []reflect.Value, cs *ConfigState) {
 	sortValues(values, cs)
 }
+
+// lcsLen returns the length of the longest common subsequence of a and b.
+func lcsLen(a, b []string) int {
+	row := make([]int, len(b)+1)
+	for i := len(a) - 1; i >= 0; i-- {
+		diag := 0
+		for j := len(b) - 1; j >= 0; j-- {
+			next := row[j]
+			switch {
+			case a[i] == b[j]:
+				row[j] = diag + 1
+			case row[j+1] > row[j]:
+				row[j] = row[j+1]
+			}
+			diag = next
+		}
+	}
+	return row[0]
+}
+
+// TestDiffLines ensures the edit scripts of diffLines turn a into b with the
+// fewest edits.
+func TestDiffLines(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		s := make([]string, r.Intn(16))
+		for i := range s {
+			s[i] = string(rune('a' + r.Intn(3)))
+		}
+		return s
+	}
+	for i := 0; i < 2000; i++ {
+		a, b := lines(), lines()
+		var gotA, gotB []string
+		edits := 0
+		for _, op := range diffLines(a, b) {
+			if op.kind != '+' {
+				gotA = append(gotA, op.line)
+			}
+			if op.kind != '-' {
+				gotB = append(gotB, op.line)
+			}
+			if op.kind != ' ' {
+				edits++
+			}
+		}
+		want := len(a) + len(b) - 2*lcsLen(a, b)
+		if strings.Join(gotA, "") != strings.Join(a, "") ||
+			strings.Join(gotB, "") != strings.Join(b, "") || edits != want {
+			t.Fatalf("diffLines(%q, %q) = %q, %q with %d edits, want %d edits",
+				a, b, gotA, gotB, edits, want)
+		}
+	}
+}
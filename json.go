@@ -0,0 +1,493 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Some constants in the form of bytes to avoid string overhead when writing
+// JSON.
+var (
+	jsonInvalidBytes   = []byte(`{"kind":"invalid"}`)
+	jsonNilBytes       = []byte(`{"type":"interface {}","kind":"interface","nil":true}`)
+	jsonTypeBytes      = []byte(`{"type":`)
+	jsonKindBytes      = []byte(`,"kind":`)
+	jsonValueBytes     = []byte(`,"value":`)
+	jsonLenBytes       = []byte(`,"len":`)
+	jsonCapBytes       = []byte(`,"cap":`)
+	jsonIsNilBytes     = []byte(`,"nil":true`)
+	jsonCircularBytes  = []byte(`,"circular":true`)
+	jsonMaxDepthBytes  = []byte(`,"maxDepth":true`)
+	jsonRedactedBytes  = []byte(`,"redacted":true`)
+	jsonPointersBytes  = []byte(`,"pointers":[`)
+	jsonMethodBytes    = []byte(`,"method":`)
+	jsonHexBytes       = []byte(`,"hex":`)
+	jsonElemsBytes     = []byte(`,"elems":[`)
+	jsonEntriesBytes   = []byte(`,"entries":[`)
+	jsonKeyBytes       = []byte(`{"key":`)
+	jsonFieldsBytes    = []byte(`,"fields":[`)
+	jsonNameBytes      = []byte(`{"name":`)
+	jsonCommaBytes     = []byte(",")
+	jsonCloseBytes     = []byte("}")
+	jsonCloseListBytes = []byte("]")
+	jsonQuoteBytes     = []byte(`"`)
+)
+
+// jsonState contains information about the state of a JSON dump operation.
+type jsonState struct {
+	w       io.Writer
+	depth   int
+	ci      *cycleInfo
+	cs      *ConfigState
+	methods ConfigState
+	buf     bytes.Buffer
+}
+
+// unpackValue returns values inside of non-nil interfaces when possible.
+func (j *jsonState) unpackValue(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// writeString writes s as a quoted JSON string.
+func (j *jsonState) writeString(s string) {
+	b := bufferGet()
+	defer bufferPut(b)
+	b.SetBytes(appendJSONString(b.Bytes(), s))
+	j.w.Write(b.Bytes())
+}
+
+// appendJSONString appends s quoted and escaped according to RFC 8259 to dst.
+// Invalid UTF-8 sequences are replaced by the Unicode replacement character.
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				dst = append(dst, "\\ufffd"...)
+			} else {
+				dst = append(dst, s[i:i+size]...)
+			}
+			i += size
+			continue
+		}
+		switch c {
+		case '"', '\\':
+			dst = append(dst, '\\', c)
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		case '\t':
+			dst = append(dst, '\\', 't')
+		default:
+			if c < 0x20 || c == 0x7f {
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			} else {
+				dst = append(dst, c)
+			}
+		}
+		i++
+	}
+	return append(dst, '"')
+}
+
+// writeInt writes a JSON member with the passed name and integer value.
+func (j *jsonState) writeInt(name []byte, val int) {
+	j.w.Write(name)
+	printInt(j.w, int64(val), 10)
+}
+
+// writePtr writes the pointer p as a quoted hexadecimal JSON string or a nil
+// member for null pointers.
+func (j *jsonState) writePtr(p uintptr) {
+	if p == 0 {
+		j.w.Write(jsonIsNilBytes)
+		return
+	}
+	j.w.Write(jsonValueBytes)
+	j.w.Write(jsonQuoteBytes)
+	printHexPtr(j.w, p)
+	j.w.Write(jsonQuoteBytes)
+}
+
+// writeFloat writes a JSON value member for the floating point val.  NaN and
+// infinities are not representable as JSON numbers, so they are written as
+// strings.
+func (j *jsonState) writeFloat(val float64, precision int) {
+	j.w.Write(jsonValueBytes)
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		j.w.Write(jsonQuoteBytes)
+		printFloat(j.w, val, precision)
+		j.w.Write(jsonQuoteBytes)
+		return
+	}
+	printFloat(j.w, val, precision)
+}
+
+// jsonPtr handles formatting of pointers by indirecting them as necessary.
+func (j *jsonState) jsonPtr(v reflect.Value) {
+	// Figure out how many levels of indirection there are by dereferencing
+	// pointers and unpacking interfaces down the chain while detecting circular
+	// references.
+	ve := derefPtr(v, j.depth, j.ci)
+
+	// Display type information.
+	j.w.Write(jsonTypeBytes)
+	j.writeString(strings.Repeat("*", j.ci.indirects) + ve.Type().String())
+	j.w.Write(jsonKindBytes)
+	j.writeString(reflect.Ptr.String())
+
+	// Display pointer information.
+	if !j.cs.DisablePointerAddresses && len(j.ci.pointerChain) > 0 {
+		j.w.Write(jsonPointersBytes)
+		for i, addr := range j.ci.pointerChain {
+			if i > 0 {
+				j.w.Write(jsonCommaBytes)
+			}
+			j.w.Write(jsonQuoteBytes)
+			printHexPtr(j.w, addr)
+			j.w.Write(jsonQuoteBytes)
+		}
+		j.w.Write(jsonCloseListBytes)
+	}
+
+	// Display dereferenced value.
+	switch {
+	case j.ci.nilFound:
+		j.w.Write(jsonIsNilBytes)
+
+	case j.ci.cycleFound:
+		j.w.Write(jsonCircularBytes)
+
+	default:
+		j.w.Write(jsonValueBytes)
+		j.json(ve)
+	}
+	j.w.Write(jsonCloseBytes)
+}
+
+// json is the main workhorse for writing a value as JSON.  It uses the passed
+// reflect value to figure out what kind of object we are dealing with and
+// writes it as a JSON object with members according to the kind.  It is a
+// recursive function, however circular data structures are detected and
+// handled properly.
+func (j *jsonState) json(v reflect.Value) {
+	// Handle invalid reflect values immediately.
+	kind := v.Kind()
+	if kind == reflect.Invalid {
+		j.w.Write(jsonInvalidBytes)
+		return
+	}
+
+	// Handle pointers specially.
+	if kind == reflect.Ptr {
+		j.jsonPtr(v)
+		return
+	}
+
+	j.w.Write(jsonTypeBytes)
+	j.writeString(v.Type().String())
+	j.w.Write(jsonKindBytes)
+	j.writeString(kind.String())
+
+	// Call Stringer/error interfaces if they exist and the handle methods
+	// flag is enabled.
+	if !j.cs.DisableMethods && kind != reflect.Interface {
+		j.buf.Reset()
+		handled := handleMethods(&j.methods, &j.buf, v, nil)
+		if j.buf.Len() > 0 {
+			j.w.Write(jsonMethodBytes)
+			j.writeString(j.buf.String())
+		}
+		if handled && !j.cs.ContinueOnMethod {
+			j.w.Write(jsonCloseBytes)
+			return
+		}
+	}
+
+	switch kind {
+	case reflect.Bool:
+		j.w.Write(jsonValueBytes)
+		if v.Bool() {
+			j.w.Write(trueBytes)
+		} else {
+			j.w.Write(falseBytes)
+		}
+
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		j.w.Write(jsonValueBytes)
+		printInt(j.w, v.Int(), 10)
+
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		j.w.Write(jsonValueBytes)
+		printUint(j.w, v.Uint(), 10)
+
+	case reflect.Float32:
+		j.writeFloat(v.Float(), 32)
+
+	case reflect.Float64:
+		j.writeFloat(v.Float(), 64)
+
+	case reflect.Complex64, reflect.Complex128:
+		precision := 64
+		if kind == reflect.Complex64 {
+			precision = 32
+		}
+		j.buf.Reset()
+		printComplex(&j.buf, v.Complex(), precision)
+		j.w.Write(jsonValueBytes)
+		j.writeString(j.buf.String())
+
+	case reflect.String:
+		j.writeInt(jsonLenBytes, v.Len())
+		j.w.Write(jsonValueBytes)
+		j.writeString(v.String())
+
+	case reflect.Slice:
+		if v.IsNil() {
+			j.w.Write(jsonIsNilBytes)
+			break
+		}
+		fallthrough
+
+	case reflect.Array:
+		j.writeInt(jsonLenBytes, v.Len())
+		if !j.cs.DisableCapacities {
+			j.writeInt(jsonCapBytes, v.Cap())
+		}
+		j.jsonSlice(v)
+
+	case reflect.Interface:
+		// The only time we should get here is for nil interfaces due to
+		// unpackValue calls.
+		if v.IsNil() {
+			j.w.Write(jsonIsNilBytes)
+		}
+
+	case reflect.Map:
+		// nil maps should be indicated as different than empty maps
+		if v.IsNil() {
+			j.w.Write(jsonIsNilBytes)
+			break
+		}
+		j.writeInt(jsonLenBytes, v.Len())
+		j.jsonMap(v)
+
+	case reflect.Struct:
+		j.jsonStruct(v)
+
+	case reflect.Chan:
+		if v.IsNil() {
+			j.w.Write(jsonIsNilBytes)
+			break
+		}
+		j.writeInt(jsonLenBytes, v.Len())
+		if !j.cs.DisableCapacities {
+			j.writeInt(jsonCapBytes, v.Cap())
+		}
+		j.writePtr(v.Pointer())
+
+	case reflect.Uintptr:
+		j.writePtr(uintptr(v.Uint()))
+
+	case reflect.UnsafePointer, reflect.Func:
+		j.writePtr(v.Pointer())
+
+	// There were not any other types at the time this code was written, but
+	// fall back to letting the default fmt package handle it if any get added.
+	default:
+		j.buf.Reset()
+		if v.CanInterface() {
+			fmt.Fprintf(&j.buf, "%v", v.Interface())
+		} else {
+			fmt.Fprintf(&j.buf, "%v", v.String())
+		}
+		j.w.Write(jsonValueBytes)
+		j.writeString(j.buf.String())
+	}
+	j.w.Write(jsonCloseBytes)
+}
+
+// enter increases the depth for the elements of a container and returns
+// whether they are within the configured maximum depth.  A maxDepth member is
+// written when they are not.
+func (j *jsonState) enter() bool {
+	j.depth++
+	if j.cs.MaxDepth != 0 && j.depth > j.cs.MaxDepth {
+		j.w.Write(jsonMaxDepthBytes)
+		return false
+	}
+	return true
+}
+
+// jsonSlice handles formatting of arrays and slices.  Byte (uint8 under
+// reflection) arrays and slices are written as a hex string.
+func (j *jsonState) jsonSlice(v reflect.Value) {
+	if j.enter() {
+		if buf, ok := byteSliceOf(j.cs, v); ok {
+			b := bufferGet()
+			b.Grow(hex.EncodedLen(len(buf)))
+			hex.Encode(b.Bytes(), buf)
+			j.w.Write(jsonHexBytes)
+			j.w.Write(jsonQuoteBytes)
+			j.w.Write(b.Bytes())
+			j.w.Write(jsonQuoteBytes)
+			bufferPut(b)
+		} else {
+			j.w.Write(jsonElemsBytes)
+			for i := 0; i < v.Len(); i++ {
+				if i > 0 {
+					j.w.Write(jsonCommaBytes)
+				}
+				j.json(j.unpackValue(v.Index(i)))
+			}
+			j.w.Write(jsonCloseListBytes)
+		}
+	}
+	j.depth--
+}
+
+// jsonMap writes the map entries as a list of key and value objects.
+func (j *jsonState) jsonMap(v reflect.Value) {
+	if j.enter() {
+		keys := v.MapKeys()
+		if j.cs.SortKeys {
+			sortValues(keys, j.cs)
+		}
+		j.w.Write(jsonEntriesBytes)
+		for i, key := range keys {
+			if i > 0 {
+				j.w.Write(jsonCommaBytes)
+			}
+			j.w.Write(jsonKeyBytes)
+			j.json(j.unpackValue(key))
+			j.w.Write(jsonValueBytes)
+			if j.cs.redactsKey(key) {
+				j.jsonRedacted(j.unpackValue(v.MapIndex(key)))
+			} else {
+				j.json(j.unpackValue(v.MapIndex(key)))
+			}
+			j.w.Write(jsonCloseBytes)
+		}
+		j.w.Write(jsonCloseListBytes)
+	}
+	j.depth--
+}
+
+// jsonRedacted writes the object of a redacted struct field or map entry,
+// which only has the type, kind and length members of v.
+func (j *jsonState) jsonRedacted(v reflect.Value) {
+	kind := v.Kind()
+	j.w.Write(jsonTypeBytes)
+	j.writeString(v.Type().String())
+	j.w.Write(jsonKindBytes)
+	j.writeString(kind.String())
+	switch kind {
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.String, reflect.Chan:
+		j.writeInt(jsonLenBytes, v.Len())
+	}
+	j.w.Write(jsonRedactedBytes)
+	j.w.Write(jsonCloseBytes)
+}
+
+// jsonStruct writes the struct fields as a list of name and value objects.
+func (j *jsonState) jsonStruct(v reflect.Value) {
+	if j.enter() {
+		vt := v.Type()
+		j.w.Write(jsonFieldsBytes)
+		printed := 0
+		for i, o := range fieldOptionsOf(vt) {
+			if o.skip || o.omitEmpty && v.Field(i).IsZero() {
+				continue
+			}
+			if printed > 0 {
+				j.w.Write(jsonCommaBytes)
+			}
+			printed++
+			name := vt.Field(i).Name
+			j.w.Write(jsonNameBytes)
+			j.writeString(name)
+			j.w.Write(jsonValueBytes)
+			if j.cs.redactsField(o, name) {
+				j.jsonRedacted(j.unpackValue(v.Field(i)))
+			} else {
+				j.json(j.unpackValue(v.Field(i)))
+			}
+			j.w.Write(jsonCloseBytes)
+		}
+		j.w.Write(jsonCloseListBytes)
+	}
+	j.depth--
+}
+
+// fjson is a helper function to consolidate the logic from the various public
+// methods which take varying writers and config states.
+func fjson(cs *ConfigState, w io.Writer, a ...interface{}) {
+	j := jsonStatePool.Get().(*jsonState)
+	defer jsonStatePut(j)
+	j.w = w
+	j.cs = cs
+	j.ci = cycleInfoGet()
+
+	// The results of the error and Stringer interfaces are written as a
+	// separate member, so they are always requested without the decoration
+	// ContinueOnMethod adds.
+	j.methods = *cs
+	j.methods.ContinueOnMethod = false
+
+	for _, arg := range a {
+		if arg == nil {
+			w.Write(jsonNilBytes)
+			w.Write(newlineBytes)
+			continue
+		}
+
+		j.ci.resetPointers()
+
+		j.depth = 0
+		j.json(reflect.ValueOf(arg))
+		w.Write(newlineBytes)
+	}
+}
+
+var jsonStatePool = sync.Pool{New: func() interface{} {
+	return new(jsonState)
+}}
+
+func jsonStatePut(j *jsonState) {
+	cycleInfoPut(j.ci)
+	j.w = nil
+	j.ci = nil
+	j.cs = nil
+	j.methods = ConfigState{}
+	jsonStatePool.Put(j)
+}
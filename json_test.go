@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/spewerspew/spew"
+)
+
+// jsonTests houses the tests to be performed against Fjson.
+var jsonTests []renderTest
+
+// addJSONTest is a helper method to append the passed config, input and
+// desired result to jsonTests.
+func addJSONTest(cs *spew.ConfigState, in interface{}, want string) {
+	jsonTests = append(jsonTests, renderTest{cs, in, want})
+}
+
+func addScalarJSONTests() {
+	cs := &spew.ConfigState{}
+	addJSONTest(cs, nil, `{"type":"interface {}","kind":"interface","nil":true}`)
+	addJSONTest(cs, true, `{"type":"bool","kind":"bool","value":true}`)
+	addJSONTest(cs, int8(-5), `{"type":"int8","kind":"int8","value":-5}`)
+	addJSONTest(cs, uint64(18446744073709551615),
+		`{"type":"uint64","kind":"uint64","value":18446744073709551615}`)
+	addJSONTest(cs, 1.5, `{"type":"float64","kind":"float64","value":1.5}`)
+	addJSONTest(cs, math.Inf(-1), `{"type":"float64","kind":"float64","value":"-Inf"}`)
+	addJSONTest(cs, complex64(complex(1, -2)),
+		`{"type":"complex64","kind":"complex64","value":"(1-2i)"}`)
+	addJSONTest(cs, "a\"\\\n\t\x00\xff<",
+		`{"type":"string","kind":"string","len":8,"value":"a\"\\\n\t\u0000\ufffd<"}`)
+	addJSONTest(cs, uintptr(0), `{"type":"uintptr","kind":"uintptr","nil":true}`)
+}
+
+func addContainerJSONTests() {
+	type jsonStruct struct {
+		Name string
+		f    float64
+		I    interface{}
+	}
+	cs := &spew.ConfigState{SortKeys: true}
+	addJSONTest(cs, []int(nil), `{"type":"[]int","kind":"slice","nil":true}`)
+	addJSONTest(cs, [2]int{1, 2}, `{"type":"[2]int","kind":"array","len":2,"cap":2,`+
+		`"elems":[{"type":"int","kind":"int","value":1},{"type":"int","kind":"int","value":2}]}`)
+	addJSONTest(&spew.ConfigState{DisableCapacities: true}, []string{"a"}, `{"type":"[]string","kind":"slice","len":1,`+
+		`"elems":[{"type":"string","kind":"string","len":1,"value":"a"}]}`)
+	addJSONTest(cs, []byte{0xde, 0xad}, `{"type":"[]uint8","kind":"slice","len":2,"cap":2,"hex":"dead"}`)
+	addJSONTest(cs, map[string]int{"b": 2, "a": 1}, `{"type":"map[string]int","kind":"map","len":2,"entries":[`+
+		`{"key":{"type":"string","kind":"string","len":1,"value":"a"},"value":{"type":"int","kind":"int","value":1}},`+
+		`{"key":{"type":"string","kind":"string","len":1,"value":"b"},"value":{"type":"int","kind":"int","value":2}}]}`)
+	addJSONTest(cs, map[int]int(nil), `{"type":"map[int]int","kind":"map","nil":true}`)
+	addJSONTest(cs, jsonStruct{"x", 1, nil}, `{"type":"spew_test.jsonStruct","kind":"struct","fields":[`+
+		`{"name":"Name","value":{"type":"string","kind":"string","len":1,"value":"x"}},`+
+		`{"name":"f","value":{"type":"float64","kind":"float64","value":1}},`+
+		`{"name":"I","value":{"type":"interface {}","kind":"interface","nil":true}}]}`)
+	addJSONTest(cs, omitted{A: 1, Skip: 2}, `{"type":"spew_test.omitted","kind":"struct","fields":[`+
+		`{"name":"A","value":{"type":"int","kind":"int","value":1}}]}`)
+	addJSONTest(cs, omitted{Opt: []int{3}}, `{"type":"spew_test.omitted","kind":"struct","fields":[`+
+		`{"name":"A","value":{"type":"int","kind":"int","value":0}},`+
+		`{"name":"Opt","value":{"type":"[]int","kind":"slice","len":1,"cap":1,`+
+		`"elems":[{"type":"int","kind":"int","value":3}]}}]}`)
+	addJSONTest(&spew.ConfigState{MaxDepth: 1}, [][]int{{1}}, `{"type":"[][]int","kind":"slice","len":1,"cap":1,"elems":[`+
+		`{"type":"[]int","kind":"slice","len":1,"cap":1,"maxDepth":true}]}`)
+}
+
+func addPointerJSONTests() {
+	cs := &spew.ConfigState{DisablePointerAddresses: true}
+	addJSONTest(cs, new(*int), `{"type":"**int","kind":"ptr","nil":true}`)
+	addJSONTest(cs, nodeCycle(), `{"type":"*spew_test.node","kind":"ptr","value":{"type":"spew_test.node","kind":"struct","fields":[`+
+		`{"name":"Name","value":{"type":"string","kind":"string","len":1,"value":"a"}},`+
+		`{"name":"Next","value":{"type":"*spew_test.node","kind":"ptr","value":{"type":"spew_test.node","kind":"struct","fields":[`+
+		`{"name":"Name","value":{"type":"string","kind":"string","len":1,"value":"b"}},`+
+		`{"name":"Next","value":{"type":"*spew_test.node","kind":"ptr","circular":true}}]}}}]}}`)
+}
+
+func addMethodJSONTests() {
+	cs := &spew.ConfigState{}
+	addJSONTest(cs, stringer("x"), `{"type":"spew_test.stringer","kind":"string","method":"stringer x"}`)
+	addJSONTest(&spew.ConfigState{ContinueOnMethod: true}, customError(1),
+		`{"type":"spew_test.customError","kind":"int","method":"error: 1","value":1}`)
+	addJSONTest(cs, panicer(1), `{"type":"spew_test.panicer","kind":"int","method":"(PANIC=test panic)","value":1}`)
+}
+
+func setupJSONTests() {
+	if len(jsonTests) == 0 {
+		addScalarJSONTests()
+		addContainerJSONTests()
+		addPointerJSONTests()
+		addMethodJSONTests()
+	}
+}
+
+// TestJSON executes all of the tests described by jsonTests and ensures the
+// output is valid JSON.
+func TestJSON(t *testing.T) {
+	setupJSONTests()
+
+	t.Logf("Running %d tests", len(jsonTests))
+	for i, test := range jsonTests {
+		buf := new(bytes.Buffer)
+		test.cs.Fjson(buf, test.in)
+		s := buf.String()
+		if want := test.want + "\n"; s != want {
+			t.Errorf("JSON #%d\n got: %s want: %s", i, s, want)
+			continue
+		}
+		if !json.Valid(buf.Bytes()) {
+			t.Errorf("JSON #%d invalid JSON: %s", i, s)
+		}
+	}
+}
+
+// TestJSONLines ensures every argument is written as a separate document.
+func TestJSONLines(t *testing.T) {
+	s := spew.Sjson(1, "a", nil)
+	want := `{"type":"int","kind":"int","value":1}` + "\n" +
+		`{"type":"string","kind":"string","len":1,"value":"a"}` + "\n" +
+		`{"type":"interface {}","kind":"interface","nil":true}` + "\n"
+	if s != want {
+		t.Errorf("JSON lines\n got: %s want: %s", s, want)
+	}
+}
@@ -0,0 +1,213 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+)
+
+// LineWidthTerminal is the value of ConfigState.LineWidth which uses the width
+// of the terminal Dump writes to.
+const LineWidthTerminal = -1
+
+// defaultLineWidth is the line width used by LineWidthTerminal when the output
+// isn't written to a terminal with a known width.
+const defaultLineWidth = 80
+
+// errorType is a reflect.Type representing error.
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// lineWidth returns the target width of the lines of Dump output written to
+// w, or 0 when values are never written inline.
+func (c *ConfigState) lineWidth(w io.Writer) int {
+	if c.LineWidth >= 0 {
+		return c.LineWidth
+	}
+	if f, ok := w.(*os.File); ok && isTerminal(w) {
+		if n := terminalWidth(f); n > 0 {
+			return n
+		}
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return defaultLineWidth
+}
+
+// columnWriter passes writes through to w while keeping track of the column
+// the output is at.  ANSI escape sequences don't take up columns.
+type columnWriter struct {
+	w      io.Writer
+	col    int
+	escape bool
+}
+
+func (c *columnWriter) Write(p []byte) (int, error) {
+	for i := 0; i < len(p); {
+		r, size := utf8.DecodeRune(p[i:])
+		i += size
+		switch {
+		case c.escape:
+			c.escape = r < '@' || r > '~' || r == '['
+		case r == '\x1b':
+			c.escape = true
+		case r == '\n':
+			c.col = 0
+		default:
+			c.col++
+		}
+	}
+	return c.w.Write(p)
+}
+
+// fitWriter collects the output of a value written inline as long as it fits
+// in max columns on a single line.  Once it doesn't, overflow is set and the
+// rest is discarded.
+type fitWriter struct {
+	buf      buffer
+	max      int
+	col      int
+	overflow bool
+}
+
+func (f *fitWriter) Write(p []byte) (int, error) {
+	if f.overflow {
+		return len(p), nil
+	}
+	f.col += utf8.RuneCount(p)
+	for _, c := range p {
+		if c == '\n' {
+			f.overflow = true
+		}
+	}
+	if f.col > f.max {
+		f.overflow = true
+	}
+	if !f.overflow {
+		f.buf.SetBytes(append(f.buf.Bytes(), p...))
+	}
+	return len(p), nil
+}
+
+// inlineState implements fmt.State for the custom formatter writing values
+// inline in Dump output.  The custom formatter shows field names for the
+// '+' flag.
+type inlineState struct {
+	w io.Writer
+}
+
+func (s inlineState) Write(p []byte) (int, error) { return s.w.Write(p) }
+func (s inlineState) Width() (int, bool)          { return 0, false }
+func (s inlineState) Precision() (int, bool)      { return 0, false }
+func (s inlineState) Flag(c int) bool             { return c == '+' }
+
+// hasMethods returns whether printing values of type t calls their error or
+// Stringer methods.
+func hasMethods(cs *ConfigState, t reflect.Type) bool {
+	if cs.DisableMethods {
+		return false
+	}
+	if t.Implements(errorType) || t.Implements(fmtStringerType) {
+		return true
+	}
+	pt := reflect.PtrTo(t)
+	return !cs.DisablePointerMethods &&
+		(pt.Implements(errorType) || pt.Implements(fmtStringerType))
+}
+
+// inline writes the array, slice, map or struct v on the rest of the current
+// line in the style of the custom formatter when it fits within the line
+// width.  It returns whether v was written.  Values which are hexdumped or
+// printed with their error or Stringer methods are never written inline.
+func (d *dumpState) inline(v reflect.Value, kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Array, reflect.Slice:
+		if ek := v.Type().Elem().Kind(); ek == reflect.Uint8 || ek == reflect.Int8 {
+			if buf, ok := byteSliceOf(d.cs, v); ok {
+				m := d.field.byteMode(d.cs).resolve(buf)
+				if m == BytesDefault || m == BytesHexdump {
+					return false
+				}
+			}
+		}
+	case reflect.Map, reflect.Struct:
+	default:
+		return false
+	}
+	if hasMethods(d.cs, v.Type()) {
+		return false
+	}
+
+	// Leave a column for the comma or parenthesis following the value.
+	fit := fitWriter{max: d.width - d.col.col - 1}
+	if fit.max <= 0 {
+		return false
+	}
+	b := bufferGet()
+	defer bufferPut(b)
+	fit.buf.SetBytes(b.Bytes())
+	f := formatState{
+		fs:     inlineState{&fit},
+		depth:  d.depth,
+		ci:     d.ci,
+		cs:     d.cs,
+		field:  d.field,
+		inline: true,
+		fit:    &fit,
+		stack:  d.fstack,
+		stats:  &d.stats,
+	}
+	d.inl = &f
+	defer func() {
+		d.fstack = f.stack
+		d.inl = nil
+	}()
+	mark, idMark, panicMark := len(d.labels.assigned), len(d.ids.order), len(d.stats.panics)
+	if d.labels.enabled() {
+		f.labels = &d.labels
+	}
+	if d.ids.enabled() {
+		f.ids = &d.ids
+	}
+	f.format(v)
+	b.SetBytes(fit.buf.Bytes())
+	if fit.overflow {
+		d.labels.rollback(mark)
+		d.ids.rollback(idMark)
+		d.stats.panics = d.stats.panics[:panicMark]
+		return false
+	}
+
+	// Format the value once more to color it.
+	if d.theme != nil {
+		d.labels.rollback(mark)
+		d.ids.rollback(idMark)
+		d.stats.panics = d.stats.panics[:panicMark]
+		f.fs = inlineState{d.w}
+		f.theme = d.theme
+		f.fit = nil
+		f.format(v)
+		return true
+	}
+	d.w.Write(b.Bytes())
+	return true
+}
@@ -0,0 +1,298 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"io"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Some constants in the form of bytes to avoid string overhead when writing
+// the methods of nil pointers.
+var (
+	nilReceiverBytes = []byte("<nil receiver: ")
+	notCalledBytes   = []byte(" not called>")
+)
+
+// MethodPolicy restricts the types whose error and Stringer interfaces are
+// invoked.  When Allow isn't empty, only the methods of the types it matches
+// are invoked.  The methods of the types Deny matches are never invoked.
+type MethodPolicy struct {
+	Allow []MethodFilter
+	Deny  []MethodFilter
+}
+
+// MethodFilter matches the types whose error and Stringer interfaces are
+// allowed or denied by a MethodPolicy.  It matches Type when it's set and
+// otherwise every type declared in the package with the import path Package.
+// Pointers match the same filters as the types they point to.
+type MethodFilter struct {
+	Type    reflect.Type
+	Package string
+}
+
+// baseType returns the type pointed to by t for unnamed pointer types and t
+// itself otherwise.
+func baseType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr && t.Name() == "" {
+		return t.Elem()
+	}
+	return t
+}
+
+// matches returns whether the filter matches the type t.
+func (f MethodFilter) matches(t reflect.Type) bool {
+	t = baseType(t)
+	if f.Type != nil {
+		return baseType(f.Type) == t
+	}
+	return t.PkgPath() == f.Package
+}
+
+// methodsAllowed returns whether the error and Stringer interfaces of the
+// values of type t may be invoked according to the MethodPolicy option.
+func (c *ConfigState) methodsAllowed(t reflect.Type) bool {
+	p := c.MethodPolicy
+	if p == nil {
+		return true
+	}
+	for _, f := range p.Deny {
+		if f.matches(t) {
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, f := range p.Allow {
+		if f.matches(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NilMethodPolicy selects how the error and Stringer interfaces of nil
+// pointers are handled.
+type NilMethodPolicy int
+
+const (
+	// NilMethodsSkip doesn't invoke the methods of nil pointers, which are
+	// displayed as <nil>.
+	NilMethodsSkip NilMethodPolicy = iota
+
+	// NilMethodsCall invokes the methods of nil pointers the same as the
+	// ones of other values.  Methods with value receivers panic, which is
+	// displayed like other panics.
+	NilMethodsCall
+
+	// NilMethodsAnnotate doesn't invoke the methods of nil pointers, but
+	// displays a marker naming the skipped method instead of <nil>.
+	NilMethodsAnnotate
+)
+
+// methodName returns the name of the method handleMethods calls for v, which
+// is Error for errors, String for Stringers and empty otherwise.
+func methodName(v reflect.Value) string {
+	t := v.Type()
+	switch {
+	case t.Implements(errorType):
+		return "Error"
+	case t.Implements(fmtStringerType):
+		return "String"
+	}
+	return ""
+}
+
+// handleNilMethods handles the error and Stringer interfaces of the nil
+// pointer v according to the NilMethods option and counts the calls in stats.
+// It returns whether it displayed v, otherwise the caller writes the nil
+// marker.
+func handleNilMethods(cs *ConfigState, w io.Writer, v reflect.Value, stats *methodStats, t *Theme) bool {
+	if cs.DisableMethods || cs.NilMethods == NilMethodsSkip || v.Kind() != reflect.Ptr ||
+		!cs.methodsAllowed(v.Type()) {
+		return false
+	}
+	name := methodName(v)
+	if name == "" {
+		return false
+	}
+	if cs.NilMethods == NilMethodsAnnotate {
+		t.start(w, tokenNil)
+		w.Write(nilReceiverBytes)
+		io.WriteString(w, name)
+		w.Write(notCalledBytes)
+		t.end(w, tokenNil)
+		return true
+	}
+	return handleMethods(cs, w, v, stats)
+}
+
+// methodKey identifies the receiver of an error or String method by its type
+// t and either by the value val itself, when it's comparable, or by the
+// address addr of the value, when it's addressable.  Comparable values are
+// keyed by value even when they're addressable, so the copy a method with a
+// value receiver passes to spew matches the value the method was called for.
+// Keys of other values are invalid and equal no other key.
+type methodKey struct {
+	t     reflect.Type
+	addr  uintptr
+	val   interface{}
+	valid bool
+}
+
+// newMethodKey returns the key of the value v, which is addressable, unless
+// the unsafe package made it so, when addressable is set.
+func newMethodKey(v reflect.Value, addressable bool) methodKey {
+	k := methodKey{t: v.Type()}
+	switch {
+	case k.t.Comparable():
+		k.val = v.Interface()
+		k.valid = true
+	case addressable:
+		k.addr = v.UnsafeAddr()
+		k.valid = true
+	}
+	return k
+}
+
+// equal returns whether the keys k and o are equal.  Keys holding interfaces
+// whose dynamic values aren't comparable are never equal.
+func (k methodKey) equal(o methodKey) (eq bool) {
+	if !k.valid || !o.valid || k.t != o.t || k.addr != o.addr {
+		return false
+	}
+	defer func() {
+		if recover() != nil {
+			eq = false
+		}
+	}()
+	return k.val == o.val
+}
+
+// methodCall is an error or String method in progress on the goroutine gid
+// for the value with the key value, which is called with the receiver with
+// the key recv.  Nested spew calls made by the method see the calls in
+// progress on their goroutine, so they can avoid calling the method of the
+// same value again and recursing without end.
+type methodCall struct {
+	value methodKey
+	recv  methodKey
+	gid   int64
+	next  *methodCall
+}
+
+// methodCalls holds the methods in progress on each goroutine by the ID of
+// the goroutine for the configs with the DetectMethodReentry option.  Its
+// count is the number of calls held, so spew calls can skip looking up the
+// calls of their goroutine when there are none at all.
+var methodCalls = struct {
+	sync.Mutex
+	count int32
+	m     map[int64]*methodCall
+}{m: make(map[int64]*methodCall)}
+
+// goroutineID returns the ID of the calling goroutine, which the runtime
+// only reports in the header of its stack trace.
+func goroutineID() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	var id int64
+	for _, c := range b[len("goroutine "):] {
+		if c < '0' || c > '9' {
+			break
+		}
+		id = id*10 + int64(c-'0')
+	}
+	return id
+}
+
+// reentryGuarded returns whether the method calls of the config cs are
+// checked for reentry, which is the case when cs has the DetectMethodReentry
+// option or another method call is in progress for a config with it.
+func reentryGuarded(cs *ConfigState) bool {
+	return cs.DetectMethodReentry || atomic.LoadInt32(&methodCalls.count) > 0
+}
+
+// newMethodCall returns the call of the method of the value with the key value
+// on the receiver recv for the config cs, or nil when the call needs no guard.
+// It reports reentered when the call matches one of the methods in progress
+// on the calling goroutine.
+func newMethodCall(cs *ConfigState, value methodKey, recv reflect.Value) (c *methodCall, reentered bool) {
+	gid := goroutineID()
+	methodCalls.Lock()
+	calls := methodCalls.m[gid]
+	methodCalls.Unlock()
+	c = &methodCall{value: value, recv: newMethodKey(recv, false), gid: gid, next: calls}
+	if calls.find(c) {
+		return nil, true
+	}
+
+	// Calls made within a guarded method are guarded as well, so methods
+	// printing each other with a config without the option are detected.
+	if !cs.DetectMethodReentry && calls == nil {
+		return nil, false
+	}
+	return c, false
+}
+
+// enter marks the method c as in progress on the goroutine gid until the
+// returned function is called.
+func (c *methodCall) enter(gid int64) (exit func()) {
+	methodCalls.Lock()
+	methodCalls.m[gid] = c
+	atomic.AddInt32(&methodCalls.count, 1)
+	methodCalls.Unlock()
+	return func() {
+		methodCalls.Lock()
+		if c.next != nil && gid == c.gid {
+			methodCalls.m[gid] = c.next
+		} else {
+			delete(methodCalls.m, gid)
+		}
+		atomic.AddInt32(&methodCalls.count, -1)
+		methodCalls.Unlock()
+	}
+}
+
+// matches returns whether n is the same method call as c, which is the case
+// when either the values or the receivers of both match.  A value passed to
+// spew by pointer matches the receiver of a call for the value it points to
+// and the other way around.
+func (c *methodCall) matches(n *methodCall) bool {
+	for _, k := range [...]methodKey{c.value, c.recv} {
+		if k.equal(n.value) || k.equal(n.recv) {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns whether a call matching n is among the calls in progress
+// starting at c.
+func (c *methodCall) find(n *methodCall) bool {
+	for ; c != nil; c = c.next {
+		if c.matches(n) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,311 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Some constants in the form of bytes to avoid string overhead when writing
+// path query results.
+var (
+	noMatchBytes     = []byte("<no match>")
+	invalidPathBytes = []byte("<invalid path: ")
+)
+
+// pathSegment is a selector or an index of a path query.
+type pathSegment struct {
+	// field is the name of the field of a selector and empty for indexes.
+	field string
+
+	// raw is the text between the brackets of an index.  key holds raw
+	// unquoted when it's a quoted string and index holds it parsed as an
+	// integer when it's one, otherwise -1.
+	raw   string
+	key   string
+	index int
+
+	// all is set for the wildcard selector .* and index [*].
+	all bool
+}
+
+// parsePath splits the path query s into its segments.  The path starts with
+// an optional dot followed by selectors, such as .Spec, and indexes, such as
+// [0], ["key"] and [key], where the wildcard * selects every field, element
+// or entry.
+func parsePath(s string) ([]pathSegment, error) {
+	var segs []pathSegment
+	i := 0
+	if strings.HasPrefix(s, ".") {
+		i++
+	}
+	for first := true; i < len(s); first = false {
+		switch {
+		case s[i] == '[':
+			i++
+			var seg pathSegment
+			if i < len(s) && s[i] == '"' {
+				q, err := strconv.QuotedPrefix(s[i:])
+				if err != nil {
+					return nil, errors.New("unterminated string")
+				}
+				seg.raw = q
+				seg.key, _ = strconv.Unquote(q)
+				i += len(q)
+				if i >= len(s) || s[i] != ']' {
+					return nil, errors.New(`missing "]"`)
+				}
+			} else {
+				n := strings.IndexByte(s[i:], ']')
+				if n < 0 {
+					return nil, errors.New(`missing "]"`)
+				}
+				seg.raw = s[i : i+n]
+				seg.key = seg.raw
+				i += n
+			}
+			if seg.raw == "" {
+				return nil, errors.New("empty index")
+			}
+			i++
+			seg.all = seg.raw == "*"
+			seg.index = -1
+			if n, err := strconv.Atoi(seg.raw); err == nil && n >= 0 {
+				seg.index = n
+			}
+			segs = append(segs, seg)
+
+		case s[i] == '.' || first:
+			if s[i] == '.' {
+				i++
+			}
+			n := strings.IndexAny(s[i:], ".[")
+			if n < 0 {
+				n = len(s) - i
+			}
+			if n == 0 {
+				return nil, errors.New("empty selector")
+			}
+			name := s[i : i+n]
+			i += n
+			segs = append(segs, pathSegment{field: name, index: -1, all: name == "*"})
+
+		default:
+			return nil, errors.New("unexpected " + strconv.QuoteRune(rune(s[i])))
+		}
+	}
+	return segs, nil
+}
+
+// pathMatch is a value selected by a path query.
+type pathMatch struct {
+	path     string
+	v        reflect.Value
+	redacted bool
+	field    fieldOptions
+}
+
+// pathQuery contains information about the state of resolving a path query.
+type pathQuery struct {
+	cs      *ConfigState
+	matches []pathMatch
+}
+
+// indirect dereferences pointers and unpacks interfaces until v is neither.
+// It returns the invalid reflect.Value for nil pointers and interfaces.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// resolve records the values the segments select below v, which is located
+// at path.  Values inside redacted fields and map entries stay redacted.  The
+// options of the field v was selected from, if any, are passed in o.
+func (q *pathQuery) resolve(v reflect.Value, path string, segs []pathSegment, redacted bool, o fieldOptions) {
+	if len(segs) == 0 {
+		q.matches = append(q.matches, pathMatch{path, v, redacted, o})
+		return
+	}
+	v = indirect(v)
+	if !v.IsValid() {
+		return
+	}
+	seg := segs[0]
+	if seg.field != "" {
+		q.resolveField(v, path, seg, segs[1:], redacted)
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice:
+		if seg.all {
+			for i := 0; i < v.Len(); i++ {
+				q.resolve(v.Index(i), indexPath(path, i), segs[1:], redacted, fieldOptions{})
+			}
+		} else if seg.index >= 0 && seg.index < v.Len() {
+			q.resolve(v.Index(seg.index), indexPath(path, seg.index), segs[1:], redacted, fieldOptions{})
+		}
+
+	case reflect.Map:
+		keys := v.MapKeys()
+		if q.cs.SortKeys {
+			sortValues(keys, q.cs)
+		}
+		for _, key := range keys {
+			kp := keyPath(q.cs, path, key)
+			if !seg.all && !matchesKey(key, seg, kp[len(path):]) {
+				continue
+			}
+			q.resolve(v.MapIndex(key), kp, segs[1:], redacted || q.cs.redactsKey(key), fieldOptions{})
+		}
+	}
+}
+
+// matchesKey returns whether the map key, which is written as the index kp in
+// paths, is selected by the index seg.  String keys are compared to the quoted
+// or bare key of the index and other keys to the index as written.
+func matchesKey(key reflect.Value, seg pathSegment, kp string) bool {
+	if key.Kind() == reflect.Interface && !key.IsNil() {
+		key = key.Elem()
+	}
+	if key.Kind() == reflect.String {
+		return key.String() == seg.key
+	}
+	return kp == "["+seg.raw+"]"
+}
+
+// resolveField resolves the remaining segments below the fields of the struct
+// v selected by seg.  Fields of embedded structs are promoted like they are
+// for Go selectors.  Fields skipped by their tags are never selected and
+// wildcards don't select the empty fields of omitempty tags.
+func (q *pathQuery) resolveField(v reflect.Value, path string, seg pathSegment, segs []pathSegment, redacted bool) {
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	vt := v.Type()
+	if seg.all {
+		for i, o := range fieldOptionsOf(vt) {
+			if o.skip || o.omitEmpty && v.Field(i).IsZero() {
+				continue
+			}
+			name := vt.Field(i).Name
+			q.resolve(v.Field(i), fieldPath(path, name), segs,
+				redacted || q.cs.redactsField(o, name), o)
+		}
+		return
+	}
+
+	sf, ok := vt.FieldByName(seg.field)
+	if !ok {
+		return
+	}
+	// Walk the embedded structs of promoted fields, which might be reached
+	// through nil pointers.
+	for _, i := range sf.Index[:len(sf.Index)-1] {
+		if v = indirect(v.Field(i)); !v.IsValid() {
+			return
+		}
+	}
+	i := sf.Index[len(sf.Index)-1]
+	o := fieldOptionsOf(v.Type())[i]
+	if o.skip {
+		return
+	}
+	q.resolve(v.Field(i), fieldPath(path, sf.Name), segs,
+		redacted || q.cs.redactsField(o, sf.Name), o)
+}
+
+// fdumpPath is a helper function to consolidate the logic from the various
+// public methods which take varying writers and config states.
+func fdumpPath(cs *ConfigState, w io.Writer, v interface{}, path string) {
+	d := dumpStateGet(w, cs)
+	defer dumpStatePut(d)
+
+	segs, err := parsePath(path)
+	if err != nil {
+		d.theme.start(d.w, tokenField)
+		io.WriteString(d.w, path)
+		d.theme.end(d.w, tokenField)
+		d.w.Write(colonSpaceBytes)
+		d.w.Write(invalidPathBytes)
+		io.WriteString(d.w, err.Error())
+		d.w.Write(closeAngleBytes)
+		d.w.Write(newlineBytes)
+		return
+	}
+
+	q := pathQuery{cs: cs}
+	q.resolve(reflect.ValueOf(v), "", segs, false, fieldOptions{})
+	if len(q.matches) == 0 {
+		d.theme.start(d.w, tokenField)
+		io.WriteString(d.w, path)
+		d.theme.end(d.w, tokenField)
+		d.w.Write(colonSpaceBytes)
+		d.theme.writeToken(d.w, tokenNil, noMatchBytes)
+		d.w.Write(newlineBytes)
+		return
+	}
+
+	// Label the pointers shared between all of the matches, the same as for
+	// the arguments of Dump.
+	if cs.ReferenceLabels {
+		d.labels.reset()
+		for _, m := range q.matches {
+			d.labels.census.collect(m.v)
+		}
+	}
+	if cs.SymbolicPointers {
+		d.ids.reset()
+	}
+
+	for _, m := range q.matches {
+		p := m.path
+		if p == "" {
+			p = rootPath
+		}
+		d.theme.start(d.w, tokenField)
+		io.WriteString(d.w, p)
+		d.theme.end(d.w, tokenField)
+		d.w.Write(colonSpaceBytes)
+
+		d.ci.resetPointers()
+		v := d.unpackValue(m.v)
+		switch {
+		case !v.IsValid():
+			d.theme.writeToken(d.w, tokenType, interfaceBytes)
+			d.w.Write(spaceBytes)
+			d.theme.writeToken(d.w, tokenNil, nilAngleBytes)
+		case m.redacted:
+			d.dumpRedacted(v)
+		default:
+			d.field = fieldState{}.enter(m.field, 0)
+			d.dump(v)
+			d.field = fieldState{}
+		}
+		d.w.Write(newlineBytes)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spewerspew/spew"
+)
+
+// pathTest is used to describe a test to be performed against FdumpPath with
+// the path query path.
+type pathTest struct {
+	renderTest
+	path string
+}
+
+// pathTests houses the tests to be performed against FdumpPath.
+var pathTests []pathTest
+
+// addPathTest is a helper method to append the passed config, input, path and
+// desired result to pathTests.
+func addPathTest(cs *spew.ConfigState, in interface{}, path, want string) {
+	pathTests = append(pathTests, pathTest{renderTest{cs, in, want}, path})
+}
+
+// pathEnv, pathContainer, pathMeta and pathSpec are used to test path queries.
+type pathEnv struct {
+	Name  string
+	Value string
+}
+
+type pathContainer struct {
+	Image string
+	Env   []pathEnv
+}
+
+type pathMeta struct {
+	Labels map[string]string
+}
+
+type pathSpec struct {
+	*pathMeta
+	Containers []*pathContainer
+	Ports      map[int]string
+	Token      string `spew:"redact"`
+	Cache      []byte `spew:"-"`
+	Flags      uint8  `spew:"hex"`
+	any        interface{}
+}
+
+// pathSpecValue returns the pathSpec the path query tests select from.
+func pathSpecValue() interface{} {
+	return struct{ Spec pathSpec }{pathSpec{
+		pathMeta: &pathMeta{Labels: map[string]string{"app": "web", "tier": "front"}},
+		Containers: []*pathContainer{
+			{Image: "nginx", Env: []pathEnv{{"A", "1"}}},
+			{Image: "redis"},
+		},
+		Ports: map[int]string{443: "https", 80: "http"},
+		Token: "secret",
+		Flags: 10,
+		any:   map[string]int{"x": 1},
+	}}
+}
+
+func addSelectorPathTests() {
+	cs := &spew.ConfigState{Indent: " ", SortKeys: true}
+	in := pathSpecValue()
+	addPathTest(cs, in, "Spec.Containers[0].Image",
+		"Spec.Containers[0].Image: (string) (len=5) \"nginx\"\n")
+	addPathTest(cs, in, ".Spec.Containers[0].Env",
+		"Spec.Containers[0].Env: ([]spew_test.pathEnv) (len=1 cap=1) {\n"+
+			" (spew_test.pathEnv) {\n  Name: (string) (len=1) \"A\",\n  Value: (string) (len=1) \"1\"\n }\n}\n")
+	addPathTest(cs, in, "Spec.Ports[80]", "Spec.Ports[80]: (string) (len=4) \"http\"\n")
+	addPathTest(cs, in, "Spec.Labels[\"app\"]", "Spec.Labels[\"app\"]: (string) (len=3) \"web\"\n")
+	addPathTest(cs, in, "Spec.Labels[tier]", "Spec.Labels[\"tier\"]: (string) (len=5) \"front\"\n")
+	addPathTest(cs, in, "Spec.any[\"x\"]", "Spec.any[\"x\"]: (int) 1\n")
+	addPathTest(cs, 5, ".", ".: (int) 5\n")
+}
+
+func addWildcardPathTests() {
+	cs := &spew.ConfigState{Indent: " ", SortKeys: true}
+	in := pathSpecValue()
+	addPathTest(cs, in, "Spec.Containers[*].Image",
+		"Spec.Containers[0].Image: (string) (len=5) \"nginx\"\n"+
+			"Spec.Containers[1].Image: (string) (len=5) \"redis\"\n")
+	addPathTest(cs, in, "Spec.Ports[*]",
+		"Spec.Ports[80]: (string) (len=4) \"http\"\n"+
+			"Spec.Ports[443]: (string) (len=5) \"https\"\n")
+	addPathTest(cs, []pathEnv{{"A", "1"}}, "[*].*",
+		"[0].Name: (string) (len=1) \"A\"\n[0].Value: (string) (len=1) \"1\"\n")
+}
+
+func addTagPathTests() {
+	cs := &spew.ConfigState{Indent: " ", SortKeys: true}
+	in := pathSpecValue()
+	addPathTest(cs, in, "Spec.Token", "Spec.Token: (string) <redacted len=6>\n")
+	addPathTest(cs, in, "Spec.Flags", "Spec.Flags: (uint8) 0xa\n")
+	addPathTest(cs, in, "Spec.Cache", "Spec.Cache: <no match>\n")
+	addPathTest(cs, omitted{A: 1, Skip: 2}, "*", "A: (int) 1\n")
+
+	rcs := &spew.ConfigState{Indent: " ", RedactPolicy: &spew.RedactPolicy{Fields: []string{"env"}}}
+	addPathTest(rcs, in, "Spec.Containers[0].Env[0].Name",
+		"Spec.Containers[0].Env[0].Name: (string) <redacted len=1>\n")
+}
+
+func addInvalidPathTests() {
+	cs := &spew.ConfigState{Indent: " ", SortKeys: true}
+	in := pathSpecValue()
+	addPathTest(cs, in, "Spec.Containers[5]", "Spec.Containers[5]: <no match>\n")
+	addPathTest(cs, in, "Spec.Containers[0", "Spec.Containers[0: <invalid path: missing \"]\">\n")
+	addPathTest(cs, in, "Spec..Image", "Spec..Image: <invalid path: empty selector>\n")
+}
+
+func setupPathTests() {
+	if len(pathTests) == 0 {
+		addSelectorPathTests()
+		addWildcardPathTests()
+		addTagPathTests()
+		addInvalidPathTests()
+	}
+}
+
+// TestDumpPath executes all of the tests described by pathTests.
+func TestDumpPath(t *testing.T) {
+	setupPathTests()
+
+	t.Logf("Running %d tests", len(pathTests))
+	for i, test := range pathTests {
+		buf := new(bytes.Buffer)
+		test.cs.FdumpPath(buf, test.in, test.path)
+		if s := buf.String(); s != test.want {
+			t.Errorf("DumpPath #%d %s\n got: %q want: %q", i, test.path, s, test.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew_test
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/spewerspew/spew"
+)
+
+// redactStruct is used to test redaction of struct fields and map entries.
+type redactStruct struct {
+	User     string
+	password string
+	APIKey   []byte
+	Headers  map[string]string
+	N        int
+}
+
+// TestRedact ensures every renderer replaces the values matched by
+// the RedactPolicy with a marker.
+func TestRedact(t *testing.T) {
+	cs := &spew.ConfigState{
+		Indent:                  " ",
+		SortKeys:                true,
+		DisablePointerAddresses: true,
+		RedactPolicy: &spew.RedactPolicy{
+			Fields:  spew.DefaultRedactFields,
+			Pattern: regexp.MustCompile(`^N$`),
+		},
+	}
+	v := redactStruct{
+		User:     "bob",
+		password: "hunter2",
+		APIKey:   []byte("k"),
+		Headers:  map[string]string{"Authorization": "Bearer x", "Accept": "*/*"},
+		N:        3,
+	}
+	w := v
+	w.password = "x"
+	w.Headers = map[string]string{"Authorization": "y", "Accept": "*/*"}
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"Sdump", cs.Sdump(v), "(spew_test.redactStruct) {\n" +
+			" User: (string) (len=3) \"bob\",\n" +
+			" password: (string) <redacted len=7>,\n" +
+			" APIKey: ([]uint8) <redacted len=1>,\n" +
+			" Headers: (map[string]string) (len=2) {\n" +
+			"  (string) (len=6) \"Accept\": (string) (len=3) \"*/*\",\n" +
+			"  (string) (len=13) \"Authorization\": (string) <redacted len=8>\n" +
+			" },\n" +
+			" N: (int) <redacted>\n" +
+			"}\n"},
+		{"%v", cs.Sprintf("%v", v), "{bob <redacted len=7> <redacted len=1> " +
+			"map[Accept:*/* Authorization:<redacted len=8>] <redacted>}"},
+		{"%#v", cs.Sprintf("%#v", v), "(spew_test.redactStruct){User:(string)bob " +
+			"password:(string)<redacted len=7> APIKey:([]uint8)<redacted len=1> " +
+			"Headers:(map[string]string)map[Accept:*/* Authorization:(string)<redacted len=8>] " +
+			"N:(int)<redacted>}"},
+		{"Sjson", cs.Sjson(v), `{"type":"spew_test.redactStruct","kind":"struct","fields":[` +
+			`{"name":"User","value":{"type":"string","kind":"string","len":3,"value":"bob"}},` +
+			`{"name":"password","value":{"type":"string","kind":"string","len":7,"redacted":true}},` +
+			`{"name":"APIKey","value":{"type":"[]uint8","kind":"slice","len":1,"redacted":true}},` +
+			`{"name":"Headers","value":{"type":"map[string]string","kind":"map","len":2,"entries":[` +
+			`{"key":{"type":"string","kind":"string","len":6,"value":"Accept"},` +
+			`"value":{"type":"string","kind":"string","len":3,"value":"*/*"}},` +
+			`{"key":{"type":"string","kind":"string","len":13,"value":"Authorization"},` +
+			`"value":{"type":"string","kind":"string","len":8,"redacted":true}}]}},` +
+			`{"name":"N","value":{"type":"int","kind":"int","redacted":true}}]}` + "\n"},
+		{"Syaml", cs.Syaml(v), "---\n" +
+			"User: bob\n" +
+			"password: \"<redacted len=7>\"\n" +
+			"APIKey: \"<redacted len=1>\"\n" +
+			"Headers:\n" +
+			"  Accept: \"*/*\"\n" +
+			"  Authorization: \"<redacted len=8>\"\n" +
+			"N: \"<redacted>\"\n"},
+		{"GoSyntax", cs.GoSyntax(v), "spew_test.redactStruct{\n" +
+			" User: \"bob\",\n" +
+			" // password: string <redacted len=7>\n" +
+			" // APIKey: []uint8 <redacted len=1>\n" +
+			" Headers: map[string]string{\n" +
+			"  \"Accept\": \"*/*\",\n" +
+			"  // \"Authorization\": string <redacted len=8>\n" +
+			" },\n" +
+			" // N: int <redacted>\n" +
+			"}"},
+		{"Sdiff", cs.Sdiff(v, w), "--- a\n+++ b\n" +
+			"@@ .password @@\n" +
+			"-(string) <redacted len=7>\n" +
+			"+(string) <redacted len=1>\n" +
+			"@@ .Headers[\"Authorization\"] @@\n" +
+			"-(string) <redacted len=8>\n" +
+			"+(string) <redacted len=1>\n"},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("Redact %s\n got: %q\nwant: %q", test.name, test.got, test.want)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	cs.Fhtml(buf, v)
+	s := buf.String()
+	for _, secret := range []string{"hunter2", "Bearer", ">k<"} {
+		if strings.Contains(s, secret) {
+			t.Errorf("Redact Fhtml shows %q: %s", secret, s)
+		}
+	}
+	if !strings.Contains(s, "&lt;redacted len=7&gt;") {
+		t.Errorf("Redact Fhtml has no marker: %s", s)
+	}
+}
+
+// TestRedactDisabled ensures nothing is redacted by default.
+func TestRedactDisabled(t *testing.T) {
+	cs := &spew.ConfigState{}
+	s := cs.Sprintf("%v", map[string]string{"token": "abc"})
+	if want := "map[token:abc]"; s != want {
+		t.Errorf("Redact disabled\n got: %s\nwant: %s", s, want)
+	}
+}
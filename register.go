@@ -0,0 +1,174 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"io"
+	"reflect"
+)
+
+// FormatterFunc writes the value v to w.  It's called by Dump and the custom
+// formatter instead of printing v themselves for the types it's registered
+// for with RegisterFormatter or RegisterInterfaceFormatter.  The type of v
+// has already been written when types are shown.
+type FormatterFunc func(w io.Writer, v reflect.Value, s *ValueState)
+
+// ValueState describes the context a FormatterFunc is called in.  It's only
+// valid for the duration of the call.
+type ValueState struct {
+	// Depth is the nesting level of the value, which is 0 for the values
+	// passed to spew.
+	Depth int
+
+	// Prefix is the indentation of the line the value starts on.  The lines
+	// written for the fields or elements of the value are indented by Prefix
+	// followed by Indent.  Both are empty for the custom formatter, which
+	// writes values on a single line.
+	Prefix string
+	Indent string
+
+	// Inline is true when the value is written by the custom formatter.
+	Inline bool
+
+	p nestedPrinter
+}
+
+// nestedPrinter is implemented by the states of the renderers which call
+// formatter funcs to give them access to the current traversal.
+type nestedPrinter interface {
+	printNested(v reflect.Value)
+	circular(v reflect.Value) bool
+}
+
+// Write writes v the way spew would write it as a field or element of the
+// value the formatter func was called for, starting at the current position
+// of the output.  Pointers are followed with the same circular reference
+// detection as the rest of the output.
+func (s *ValueState) Write(v reflect.Value) {
+	s.p.printNested(v)
+}
+
+// Circular returns whether v is a pointer to a value which is already being
+// written, which Write would print as a circular reference.
+func (s *ValueState) Circular(v reflect.Value) bool {
+	return s.p.circular(v)
+}
+
+// ifaceFormatter is a formatter func registered for the types which implement
+// an interface.
+type ifaceFormatter struct {
+	iface reflect.Type
+	fn    FormatterFunc
+}
+
+// formatterRegistry holds the formatter funcs registered with a ConfigState.
+// It isn't changed once a ConfigState refers to it, so copies of the
+// ConfigState share it and still compare equal until one of them registers
+// another formatter.
+type formatterRegistry struct {
+	types  map[reflect.Type]FormatterFunc
+	ifaces []ifaceFormatter
+}
+
+// clone returns a copy of r, which may be nil, that can be changed without
+// changing r.
+func (r *formatterRegistry) clone() *formatterRegistry {
+	n := &formatterRegistry{types: make(map[reflect.Type]FormatterFunc)}
+	if r == nil {
+		return n
+	}
+	for t, fn := range r.types {
+		n.types[t] = fn
+	}
+	n.ifaces = append(n.ifaces, r.ifaces...)
+	return n
+}
+
+// RegisterFormatter registers fn to write the values of type t instead of the
+// default representation of Dump and the custom formatter, which includes the
+// result of error and Stringer methods.  Registering a nil fn removes the
+// formatter of t.  Formatters registered for a type take precedence over
+// the ones registered for interfaces.
+//
+// Formatters must be registered before c is used and must not be registered
+// concurrently with printing.  Copies of c share the formatters registered
+// before they were made, but registering on a copy doesn't change the others.
+func (c *ConfigState) RegisterFormatter(t reflect.Type, fn FormatterFunc) {
+	// Copy the formatters on write since copies of c share them.
+	r := c.formatters.clone()
+	if fn == nil {
+		delete(r.types, t)
+	} else {
+		r.types[t] = fn
+	}
+	c.formatters = r
+}
+
+// RegisterInterfaceFormatter registers fn to write the values of every type
+// which implements the interface type iface, such as third-party types which
+// can't be given error or Stringer methods.  Interfaces are consulted in the
+// order they were registered.  Registering a nil fn removes the formatter of
+// iface.  RegisterInterfaceFormatter panics if iface isn't an interface type.
+//
+// Formatters must be registered before c is used and must not be registered
+// concurrently with printing.  Copies of c share the formatters registered
+// before they were made, but registering on a copy doesn't change the others.
+func (c *ConfigState) RegisterInterfaceFormatter(iface reflect.Type, fn FormatterFunc) {
+	if iface.Kind() != reflect.Interface {
+		panic("spew: RegisterInterfaceFormatter of non-interface type " + iface.String())
+	}
+
+	// Copy the formatters on write since copies of c share them.
+	r := c.formatters.clone()
+	ifaces := r.ifaces[:0]
+	found := false
+	for _, f := range r.ifaces {
+		if f.iface == iface {
+			found = true
+			if fn == nil {
+				continue
+			}
+			f.fn = fn
+		}
+		ifaces = append(ifaces, f)
+	}
+	if !found && fn != nil {
+		ifaces = append(ifaces, ifaceFormatter{iface, fn})
+	}
+	r.ifaces = ifaces
+	c.formatters = r
+}
+
+// formatterOf returns the formatter func registered for the type of v or nil
+// when there is none.
+func (c *ConfigState) formatterOf(v reflect.Value) FormatterFunc {
+	r := c.formatters
+	if r == nil {
+		return nil
+	}
+	t := v.Type()
+	if fn, ok := r.types[t]; ok {
+		return fn
+	}
+	for _, f := range r.ifaces {
+		if t.Implements(f.iface) {
+			return f.fn
+		}
+	}
+	return nil
+}
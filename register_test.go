@@ -0,0 +1,188 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew_test
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/spewerspew/spew"
+)
+
+// celsius is used to test formatter funcs registered for a type.
+type celsius float64
+
+// celsiusFormatter writes celsius values with their unit.
+func celsiusFormatter(w io.Writer, v reflect.Value, s *spew.ValueState) {
+	io.WriteString(w, strconv.FormatFloat(v.Float(), 'f', -1, 64)+"°C")
+}
+
+// chain is used to test formatter funcs which write nested values.
+type chain struct {
+	name string
+	next *chain
+}
+
+// chainFormatter writes the name of a chain followed by the next one.
+func chainFormatter(w io.Writer, v reflect.Value, s *spew.ValueState) {
+	io.WriteString(w, v.Field(0).String()+" -> ")
+	if next := v.Field(1); s.Circular(next) {
+		io.WriteString(w, "<cycle>")
+	} else {
+		s.Write(next)
+	}
+}
+
+// grid is used to test formatter funcs which write multiple lines.
+type grid [][]int
+
+// gridFormatter writes the rows of a grid on separate lines unless it's
+// written inline.
+func gridFormatter(w io.Writer, v reflect.Value, s *spew.ValueState) {
+	if s.Inline {
+		io.WriteString(w, "grid")
+		return
+	}
+	io.WriteString(w, "{\n")
+	for i := 0; i < v.Len(); i++ {
+		io.WriteString(w, s.Prefix+s.Indent+"row "+strconv.Itoa(i)+": ")
+		s.Write(v.Index(i))
+		io.WriteString(w, "\n")
+	}
+	io.WriteString(w, s.Prefix+"}")
+}
+
+// shape is used to test formatter funcs registered for an interface.
+type shape interface {
+	Area() float64
+}
+
+// square is a shape.
+type square float64
+
+func (s square) Area() float64 { return float64(s * s) }
+
+// shapeFormatter writes the area of a shape.
+func shapeFormatter(w io.Writer, v reflect.Value, s *spew.ValueState) {
+	io.WriteString(w, "area="+strconv.FormatFloat(v.Interface().(shape).Area(), 'f', -1, 64))
+}
+
+// TestRegisterFormatter ensures registered formatter funcs are used by Dump
+// and the custom formatter.
+func TestRegisterFormatter(t *testing.T) {
+	cs := &spew.ConfigState{Indent: " ", DisablePointerAddresses: true}
+	cs.RegisterFormatter(reflect.TypeOf(celsius(0)), celsiusFormatter)
+	cs.RegisterFormatter(reflect.TypeOf(chain{}), chainFormatter)
+	cs.RegisterFormatter(reflect.TypeOf(grid{}), gridFormatter)
+	cs.RegisterFormatter(reflect.TypeOf(stringer("")), celsiusFormatter)
+	cs.RegisterFormatter(reflect.TypeOf(stringer("")), nil)
+	cs.RegisterInterfaceFormatter(reflect.TypeOf((*shape)(nil)).Elem(), shapeFormatter)
+
+	c := &chain{name: "a"}
+	c.next = &chain{name: "b", next: c}
+	nested := struct {
+		T celsius
+		G grid
+		S shape
+	}{21.5, grid{{1}, {2, 3}}, square(2)}
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"Sdump celsius", cs.Sdump(celsius(21.5)), "(spew_test.celsius) 21.5°C\n"},
+		{"Sdump *celsius", cs.Sdump(&nested.T), "(*spew_test.celsius)(21.5°C)\n"},
+		{"%v celsius", cs.Sprintf("%v", celsius(-3)), "-3°C"},
+		{"%#v celsius", cs.Sprintf("%#v", celsius(-3)), "(spew_test.celsius)-3°C"},
+		{"Sdump chain", cs.Sdump(c), "(*spew_test.chain)(a -> (*spew_test.chain)(b -> <cycle>))\n"},
+		{"%v chain", cs.Sprintf("%v", c), "<*>a -> <*>b -> <cycle>"},
+		{"Sdump nested", cs.Sdump(nested), "(struct { T spew_test.celsius; G spew_test.grid; S spew_test.shape }) {\n" +
+			" T: (spew_test.celsius) 21.5°C,\n" +
+			" G: (spew_test.grid) {\n" +
+			"  row 0: ([]int) (len=1 cap=1) {\n" +
+			"   (int) 1\n" +
+			"  }\n" +
+			"  row 1: ([]int) (len=2 cap=2) {\n" +
+			"   (int) 2,\n" +
+			"   (int) 3\n" +
+			"  }\n" +
+			" },\n" +
+			" S: (spew_test.square) area=4\n" +
+			"}\n"},
+		{"%v nested", cs.Sprintf("%v", nested), "{21.5°C grid area=4}"},
+		{"Sprint stringer", cs.Sprint(stringer("x")), "stringer x"},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("RegisterFormatter %s\n got: %q\nwant: %q", test.name, test.got, test.want)
+		}
+	}
+}
+
+// TestRegisterInterfaceFormatterPanic ensures registering a formatter func
+// for a type which isn't an interface panics.
+func TestRegisterInterfaceFormatterPanic(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterInterfaceFormatter did not panic for a non-interface type")
+		}
+	}()
+	cs := &spew.ConfigState{}
+	cs.RegisterInterfaceFormatter(reflect.TypeOf(0), celsiusFormatter)
+}
+
+// TestRegisterFormatterCopy ensures formatter funcs registered on a copy of a
+// config don't change the config it was copied from and the other way around.
+func TestRegisterFormatterCopy(t *testing.T) {
+	shapeType := reflect.TypeOf((*shape)(nil)).Elem()
+	cs := spew.ConfigState{}
+	cs.RegisterFormatter(reflect.TypeOf(celsius(0)), celsiusFormatter)
+	cs.RegisterInterfaceFormatter(shapeType, shapeFormatter)
+
+	cs2 := cs
+	cs2.RegisterFormatter(reflect.TypeOf(celsius(0)), nil)
+	cs2.RegisterInterfaceFormatter(shapeType, nil)
+	cs.RegisterInterfaceFormatter(reflect.TypeOf((*fmt.Stringer)(nil)).Elem(),
+		func(w io.Writer, v reflect.Value, s *spew.ValueState) {
+			io.WriteString(w, "<"+v.String()+">")
+		})
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"original celsius", cs.Sprint(celsius(1)), "1°C"},
+		{"original shape", cs.Sprint(square(2)), "area=4"},
+		{"original stringer", cs.Sprint(stringer("x")), "<x>"},
+		{"copy celsius", cs2.Sprint(celsius(1)), "1"},
+		{"copy shape", cs2.Sprint(square(2)), "2"},
+		{"copy stringer", cs2.Sprint(stringer("x")), "stringer x"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("RegisterFormatter %s\n got: %q\nwant: %q", test.name, test.got, test.want)
+		}
+	}
+}
@@ -17,7 +17,11 @@
 
 package spew
 
-import "io"
+import (
+	"context"
+	"io"
+	"reflect"
+)
 
 // Errorf is a wrapper for fmt.Errorf that treats each argument as if it were
 // passed with a default Formatter interface returned by NewFormatter.  It
@@ -141,12 +145,61 @@ func Fdump(w io.Writer, a ...interface{}) {
 	Config.Fdump(w, a...)
 }
 
+// FdumpErr formats and displays the passed arguments to io.Writer w exactly
+// the same as Fdump, but stops at the first write that fails.  It returns the
+// number of bytes written and the error of the failed write, if any.
+func FdumpErr(w io.Writer, a ...interface{}) (n int64, err error) {
+	return Config.FdumpErr(w, a...)
+}
+
+// FdumpReport formats and displays the passed arguments to io.Writer w
+// exactly the same as FdumpErr and returns a report with the number of bytes
+// written, the counts of the error and Stringer interface methods called and
+// the access paths and stack traces of the ones which panicked.
+func FdumpReport(w io.Writer, a ...interface{}) (DumpReport, error) {
+	return Config.FdumpReport(w, a...)
+}
+
+// FdumpContext formats and displays the passed arguments to io.Writer w
+// exactly the same as FdumpErr, but also stops once ctx is done.  See
+// ConfigState.FdumpContext for details.
+func FdumpContext(ctx context.Context, w io.Writer, a ...interface{}) (n int64, err error) {
+	return Config.FdumpContext(ctx, w, a...)
+}
+
 // Sdump returns a string with the passed arguments formatted exactly the same
 // as Dump.
 func Sdump(a ...interface{}) string {
 	return Config.Sdump(a...)
 }
 
+// SdumpContext returns a string with the passed arguments formatted exactly
+// the same as Dump, which stops once ctx is done.  It returns the error of ctx
+// if it stopped early.
+func SdumpContext(ctx context.Context, a ...interface{}) (string, error) {
+	return Config.SdumpContext(ctx, a...)
+}
+
+// FdumpPath dumps the values selected by the path query path inside v to
+// io.Writer w.  See ConfigState.DumpPath for the path syntax.
+func FdumpPath(w io.Writer, v interface{}, path string) {
+	Config.FdumpPath(w, v, path)
+}
+
+// DumpPath displays only the values selected by the path query path inside v,
+// such as "Spec.Containers[*].Image", to standard out.  Every match is dumped
+// the same way as Dump labeled with its access path.  See
+// ConfigState.DumpPath for the path syntax.
+func DumpPath(v interface{}, path string) {
+	Config.DumpPath(v, path)
+}
+
+// SdumpPath returns a string with the values selected by the path query path
+// inside v formatted exactly the same as DumpPath.
+func SdumpPath(v interface{}, path string) string {
+	return Config.SdumpPath(v, path)
+}
+
 /*
 Dump displays the passed parameters to standard out with newlines, customizable
 indentation, and additional debug information such as complete types and all
@@ -173,3 +226,100 @@ get the formatted result as a string.
 func Dump(a ...interface{}) {
 	Config.Dump(a...)
 }
+
+// Fdiff writes the differences between a and b to io.Writer w.  It formats
+// exactly the same as Diff.
+func Fdiff(w io.Writer, a, b interface{}) {
+	Config.Fdiff(w, a, b)
+}
+
+// Sdiff returns a string with the differences between a and b formatted
+// exactly the same as Diff.  The string is empty when a and b are equal.
+func Sdiff(a, b interface{}) string {
+	return Config.Sdiff(a, b)
+}
+
+/*
+Diff displays the structural differences between a and b to standard out.  Both
+values are walked together the same way as Dump and only the subtrees which
+differ are displayed as unified diff hunks of their Dump output.  Each hunk is
+annotated with the access path of the subtree and includes a few lines of
+unchanged context.  Nothing is displayed when the values are equal.
+
+The configuration options are controlled by an exported package global,
+spew.Config.  See ConfigState for options documentation.
+
+See Fdiff if you would prefer displaying to an arbitrary io.Writer or Sdiff to
+get the formatted result as a string.
+*/
+func Diff(a, b interface{}) {
+	Config.Diff(a, b)
+}
+
+// Fjson writes the passed arguments to io.Writer w as JSON, one document per
+// line.  See ConfigState.Fjson for details of the members.
+func Fjson(w io.Writer, a ...interface{}) {
+	Config.Fjson(w, a...)
+}
+
+// Sjson returns a string with the passed arguments formatted exactly the same
+// as Fjson.
+func Sjson(a ...interface{}) string {
+	return Config.Sjson(a...)
+}
+
+// GoSyntax returns the passed value formatted as a Go expression which
+// evaluates to an equal value.  See ConfigState.GoSyntax for details.
+func GoSyntax(v interface{}) string {
+	return Config.GoSyntax(v)
+}
+
+// FgoSyntax writes the passed value to io.Writer w formatted exactly the same
+// as GoSyntax.
+func FgoSyntax(w io.Writer, v interface{}) {
+	Config.FgoSyntax(w, v)
+}
+
+// Fhtml writes the passed arguments to io.Writer w as a self-contained HTML
+// document with collapsible nodes.  See ConfigState.Fhtml for details.
+func Fhtml(w io.Writer, a ...interface{}) {
+	Config.Fhtml(w, a...)
+}
+
+// Fyaml writes the passed arguments to io.Writer w as YAML, one document per
+// argument.  See ConfigState.Fyaml for details.
+func Fyaml(w io.Writer, a ...interface{}) {
+	Config.Fyaml(w, a...)
+}
+
+// Syaml returns a string with the passed arguments formatted exactly the same
+// as Fyaml.
+func Syaml(a ...interface{}) string {
+	return Config.Syaml(a...)
+}
+
+// Fflat writes the passed arguments to io.Writer w with every leaf value on
+// its own line prefixed by its access path.  See ConfigState.Fflat for
+// details.
+func Fflat(w io.Writer, a ...interface{}) {
+	Config.Fflat(w, a...)
+}
+
+// Sflat returns a string with the passed arguments formatted exactly the same
+// as Fflat.
+func Sflat(a ...interface{}) string {
+	return Config.Sflat(a...)
+}
+
+// RegisterFormatter registers fn to write the values of type t in the output
+// of the top-level functions.  See ConfigState.RegisterFormatter for details.
+func RegisterFormatter(t reflect.Type, fn FormatterFunc) {
+	Config.RegisterFormatter(t, fn)
+}
+
+// RegisterInterfaceFormatter registers fn to write the values of the types
+// which implement the interface type iface in the output of the top-level
+// functions.  See ConfigState.RegisterInterfaceFormatter for details.
+func RegisterInterfaceFormatter(iface reflect.Type, fn FormatterFunc) {
+	Config.RegisterInterfaceFormatter(iface, fn)
+}
@@ -19,8 +19,10 @@ package spew_test
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/spewerspew/spew"
@@ -318,3 +320,30 @@ func TestSpew(t *testing.T) {
 		}
 	}
 }
+
+// TestConfigStateComparable ensures configs can be compared with == and used
+// as map keys, and that copies are equal until one of them is changed.
+func TestConfigStateComparable(t *testing.T) {
+	cs := spew.ConfigState{
+		Indent:       " ",
+		RedactPolicy: &spew.RedactPolicy{Fields: spew.DefaultRedactFields},
+		MethodPolicy: &spew.MethodPolicy{Deny: []spew.MethodFilter{{Package: "fmt"}}},
+	}
+	cs.RegisterFormatter(reflect.TypeOf(0), func(w io.Writer, v reflect.Value, s *spew.ValueState) {
+		io.WriteString(w, "int")
+	})
+
+	cs2 := cs
+	if cs2 != cs {
+		t.Errorf("ConfigState copy isn't equal to the original")
+	}
+	cs2.RegisterFormatter(reflect.TypeOf(0), nil)
+	if cs2 == cs {
+		t.Errorf("ConfigState copy with other formatters is equal to the original")
+	}
+
+	configs := map[spew.ConfigState]string{cs: "cs", cs2: "cs2"}
+	if len(configs) != 2 || configs[cs] != "cs" {
+		t.Errorf("ConfigState map keys mismatch: %d keys, %q", len(configs), configs[cs])
+	}
+}
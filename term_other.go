@@ -0,0 +1,29 @@
+// Copyright (c) 2021 Anner van Hardenbroek
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// NOTE: Due to the following build constraints, this file will only be compiled
+// on systems without terminal size support or when the unsafe package isn't
+// available.
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd) || js || appengine || safe || purego || disableunsafe
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd js appengine safe purego disableunsafe
+
+package spew
+
+import "os"
+
+// terminalWidth returns the number of columns of the terminal f, which can't
+// be determined on this system.
+func terminalWidth(f *os.File) int {
+	return 0
+}
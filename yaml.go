@@ -0,0 +1,515 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// yamlIndent is the string used for each indentation level of YAML output.
+// YAML doesn't allow tabs for indentation, so cs.Indent is not used.
+const yamlIndent = "  "
+
+// Some constants in the form of bytes to avoid string overhead when writing
+// YAML.
+var (
+	yamlDocumentBytes = []byte("---")
+	yamlNullBytes     = []byte("null")
+	yamlEmptyMapBytes = []byte("{}")
+	yamlEmptySeqBytes = []byte("[]")
+	yamlItemBytes     = []byte("-")
+	yamlKeyBytes      = []byte("?")
+	yamlBinaryBytes   = []byte("!!binary ")
+	yamlCommentBytes  = []byte("  #")
+	yamlAnchorBytes   = []byte("&")
+	yamlAliasBytes    = []byte("*")
+	yamlMaxDepthBytes = []byte("<max depth reached>")
+)
+
+// yamlState contains information about the state of a YAML dump operation.
+type yamlState struct {
+	w                io.Writer
+	depth            int
+	ignoreNextIndent bool
+	ci               *cycleInfo
+	cs               *ConfigState
+	methods          ConfigState
+	census           pointerCensus
+	anchors          map[ptrKey]string
+	buf              bytes.Buffer
+}
+
+// indent performs indentation according to the depth level.  The entries of
+// the top-level collection aren't indented.
+func (y *yamlState) indent() {
+	if y.ignoreNextIndent {
+		y.ignoreNextIndent = false
+		return
+	}
+	for i := 1; i < y.depth; i++ {
+		io.WriteString(y.w, yamlIndent)
+	}
+}
+
+// unpackValue returns values inside of non-nil interfaces when possible.
+func (y *yamlState) unpackValue(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// yamlPlain returns whether s can be written as a plain YAML scalar without
+// being mistaken for another type or YAML syntax.
+func yamlPlain(s string) bool {
+	if s == "" || s[0] == ' ' || s[len(s)-1] == ' ' {
+		return false
+	}
+	c := s[0]
+	if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '/') {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '_' || c == '/' || c == '.' || c == '-' || c == ' ') {
+			return false
+		}
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null":
+		return false
+	}
+	return true
+}
+
+// writeString writes s as a plain scalar when possible and as a double-quoted
+// scalar otherwise.
+func (y *yamlState) writeString(s string) {
+	if yamlPlain(s) {
+		io.WriteString(y.w, s)
+		return
+	}
+	b := bufferGet()
+	defer bufferPut(b)
+	b.SetBytes(appendJSONString(b.Bytes(), s))
+	y.w.Write(b.Bytes())
+}
+
+// writePtr writes the pointer p as a hexadecimal integer or null for null
+// pointers.
+func (y *yamlState) writePtr(p uintptr) {
+	if p == 0 {
+		y.w.Write(yamlNullBytes)
+		return
+	}
+	printHexPtr(y.w, p)
+}
+
+// isScalar returns whether values of the passed kind are written as a YAML
+// scalar.
+func (y *yamlState) isScalar(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.Struct:
+		return false
+	}
+	return true
+}
+
+// scalar writes v as a YAML scalar.
+func (y *yamlState) scalar(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			y.w.Write(trueBytes)
+		} else {
+			y.w.Write(falseBytes)
+		}
+
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		printInt(y.w, v.Int(), 10)
+
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		printUint(y.w, v.Uint(), 10)
+
+	case reflect.Float32, reflect.Float64:
+		precision := 64
+		if v.Kind() == reflect.Float32 {
+			precision = 32
+		}
+		switch f := v.Float(); {
+		case math.IsNaN(f):
+			io.WriteString(y.w, ".nan")
+		case math.IsInf(f, 1):
+			io.WriteString(y.w, ".inf")
+		case math.IsInf(f, -1):
+			io.WriteString(y.w, "-.inf")
+		default:
+			printFloat(y.w, f, precision)
+		}
+
+	case reflect.Complex64, reflect.Complex128:
+		precision := 64
+		if v.Kind() == reflect.Complex64 {
+			precision = 32
+		}
+		y.buf.Reset()
+		printComplex(&y.buf, v.Complex(), precision)
+		y.writeString(y.buf.String())
+
+	case reflect.String:
+		y.writeString(v.String())
+
+	case reflect.Uintptr:
+		y.writePtr(uintptr(v.Uint()))
+
+	case reflect.UnsafePointer, reflect.Chan, reflect.Func:
+		y.writePtr(v.Pointer())
+
+	case reflect.Interface:
+		// The only time we should get here is for nil interfaces due to
+		// unpackValue calls.
+		y.w.Write(yamlNullBytes)
+	}
+}
+
+// comment writes the trailing comment of a node, which consists of its type
+// when YAMLTypeComments is set followed by any additional notes.
+func (y *yamlState) comment(typeName string, notes ...[]byte) {
+	if !y.cs.YAMLTypeComments && len(notes) == 0 {
+		return
+	}
+	y.w.Write(yamlCommentBytes)
+	if y.cs.YAMLTypeComments {
+		y.w.Write(spaceBytes)
+		io.WriteString(y.w, typeName)
+	}
+	for _, note := range notes {
+		y.w.Write(spaceBytes)
+		y.w.Write(note)
+	}
+}
+
+// yamlPtr handles formatting of pointers by indirecting them as necessary.
+// Pointers which are reached more than once are written with an anchor the
+// first time and as an alias afterwards.
+func (y *yamlState) yamlPtr(v reflect.Value, compact bool) {
+	// Figure out how many levels of indirection there are by dereferencing
+	// pointers and unpacking interfaces down the chain while detecting circular
+	// references.
+	ve := derefPtr(v, y.depth, y.ci)
+	typeName := strings.Repeat("*", y.ci.indirects) + ve.Type().String()
+
+	switch {
+	case y.ci.nilFound:
+		y.w.Write(spaceBytes)
+		y.w.Write(yamlNullBytes)
+		y.comment(typeName)
+		y.w.Write(newlineBytes)
+
+	case y.ci.cycleFound:
+		y.w.Write(spaceBytes)
+		if name, ok := y.anchors[pointerKey(ve)]; ok {
+			y.w.Write(yamlAliasBytes)
+			io.WriteString(y.w, name)
+			y.comment(typeName)
+		} else {
+			y.w.Write(yamlNullBytes)
+			y.comment(typeName, circularBytes)
+		}
+		y.w.Write(newlineBytes)
+
+	default:
+		key := ptrKey{y.ci.pointerChain[len(y.ci.pointerChain)-1], ve.Type()}
+		if name, ok := y.anchors[key]; ok {
+			y.w.Write(spaceBytes)
+			y.w.Write(yamlAliasBytes)
+			io.WriteString(y.w, name)
+			y.comment(typeName)
+			y.w.Write(newlineBytes)
+			return
+		}
+		var anchor string
+		if y.census.counts[key] > 1 {
+			anchor = "p" + strconv.Itoa(len(y.anchors)+1)
+			y.anchors[key] = anchor
+		}
+		y.node(ve, anchor, typeName, compact)
+	}
+}
+
+// value writes v after the indicator of a document, mapping entry or sequence
+// item has been written.  Block collections start on the same line as a
+// sequence item when compact is set.
+func (y *yamlState) value(v reflect.Value, compact bool) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		y.w.Write(spaceBytes)
+		y.w.Write(yamlNullBytes)
+		y.w.Write(newlineBytes)
+
+	case reflect.Ptr:
+		y.yamlPtr(v, compact)
+
+	default:
+		y.node(v, "", v.Type().String(), compact)
+	}
+}
+
+// node is the main workhorse for writing a value as YAML.  It uses the passed
+// reflect value to figure out what kind of object we are dealing with and
+// writes it as a scalar, mapping or sequence.  It is a recursive function,
+// however circular data structures are detected and handled properly.
+func (y *yamlState) node(v reflect.Value, anchor, typeName string, compact bool) {
+	if anchor != "" {
+		y.w.Write(spaceBytes)
+		y.w.Write(yamlAnchorBytes)
+		io.WriteString(y.w, anchor)
+	}
+
+	// Call Stringer/error interfaces if they exist and the handle methods
+	// flag is enabled.  A panic is kept as a note in the comment.
+	kind := v.Kind()
+	var note []byte
+	if !y.cs.DisableMethods && kind != reflect.Interface {
+		y.buf.Reset()
+		handled := handleMethods(&y.methods, &y.buf, v, nil)
+		if handled && !y.cs.ContinueOnMethod {
+			y.w.Write(spaceBytes)
+			y.writeString(y.buf.String())
+			y.comment(typeName)
+			y.w.Write(newlineBytes)
+			return
+		}
+		if !handled && y.buf.Len() > 0 {
+			note = append(note, y.buf.Bytes()...)
+		}
+	}
+
+	var notes [][]byte
+	if note != nil {
+		notes = append(notes, note)
+	}
+	if y.isScalar(kind) {
+		y.w.Write(spaceBytes)
+		y.scalar(v)
+		y.comment(typeName, notes...)
+		y.w.Write(newlineBytes)
+		return
+	}
+
+	// Only collections are left at this point.
+	var numEntries int
+	empty := yamlEmptyMapBytes
+	switch kind {
+	case reflect.Slice:
+		if v.IsNil() {
+			y.w.Write(spaceBytes)
+			y.w.Write(yamlNullBytes)
+			y.comment(typeName, notes...)
+			y.w.Write(newlineBytes)
+			return
+		}
+		fallthrough
+
+	case reflect.Array:
+		numEntries = v.Len()
+		empty = yamlEmptySeqBytes
+
+	case reflect.Map:
+		if v.IsNil() {
+			y.w.Write(spaceBytes)
+			y.w.Write(yamlNullBytes)
+			y.comment(typeName, notes...)
+			y.w.Write(newlineBytes)
+			return
+		}
+		numEntries = v.Len()
+
+	case reflect.Struct:
+		numEntries = fieldsLeft(v, fieldOptionsOf(v.Type()), 0)
+	}
+
+	y.depth++
+	defer func() { y.depth-- }()
+	maxDepth := y.cs.MaxDepth != 0 && y.depth > y.cs.MaxDepth
+	if maxDepth {
+		notes = append(notes, yamlMaxDepthBytes)
+	}
+
+	// Byte arrays and slices are written as base64 encoded binary data.
+	if kind != reflect.Struct && kind != reflect.Map && !maxDepth {
+		if buf, ok := byteSliceOf(y.cs, v); ok {
+			y.w.Write(spaceBytes)
+			y.w.Write(yamlBinaryBytes)
+			enc := base64.NewEncoder(base64.StdEncoding, y.w)
+			enc.Write(buf)
+			enc.Close()
+			y.comment(typeName, notes...)
+			y.w.Write(newlineBytes)
+			return
+		}
+	}
+
+	if numEntries == 0 || maxDepth {
+		y.w.Write(spaceBytes)
+		y.w.Write(empty)
+		y.comment(typeName, notes...)
+		y.w.Write(newlineBytes)
+		return
+	}
+
+	// Block collections either start on the next line or, for sequence
+	// items without any properties, right after the indicator.
+	if compact && anchor == "" && !y.cs.YAMLTypeComments && len(notes) == 0 {
+		y.w.Write(spaceBytes)
+		y.ignoreNextIndent = true
+	} else {
+		y.comment(typeName, notes...)
+		y.w.Write(newlineBytes)
+	}
+
+	switch kind {
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < numEntries; i++ {
+			y.indent()
+			y.w.Write(yamlItemBytes)
+			y.value(y.unpackValue(v.Index(i)), true)
+		}
+
+	case reflect.Map:
+		keys := v.MapKeys()
+		if y.cs.SortKeys {
+			sortValues(keys, y.cs)
+		}
+		for _, key := range keys {
+			y.yamlKey(y.unpackValue(key))
+			if y.cs.redactsKey(key) {
+				y.redacted(y.unpackValue(v.MapIndex(key)))
+				continue
+			}
+			y.value(y.unpackValue(v.MapIndex(key)), false)
+		}
+
+	case reflect.Struct:
+		vt := v.Type()
+		for i, o := range fieldOptionsOf(vt) {
+			if o.skip || o.omitEmpty && v.Field(i).IsZero() {
+				continue
+			}
+			name := vt.Field(i).Name
+			y.indent()
+			io.WriteString(y.w, name)
+			y.w.Write(colonBytes)
+			if y.cs.redactsField(o, name) {
+				y.redacted(y.unpackValue(v.Field(i)))
+				continue
+			}
+			y.value(y.unpackValue(v.Field(i)), false)
+		}
+	}
+}
+
+// redacted writes the marker of a redacted struct field or map entry as a
+// string scalar.
+func (y *yamlState) redacted(v reflect.Value) {
+	y.w.Write(spaceBytes)
+	y.buf.Reset()
+	printRedacted(&y.buf, v, nil)
+	y.writeString(y.buf.String())
+	y.comment(v.Type().String())
+	y.w.Write(newlineBytes)
+}
+
+// yamlKey writes the key of a mapping entry including the trailing colon.
+// Keys which can't be written as a scalar are written as complex keys.
+func (y *yamlState) yamlKey(key reflect.Value) {
+	y.indent()
+	switch key.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Int, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Uint, reflect.Float32, reflect.Float64, reflect.String:
+		if !y.cs.DisableMethods {
+			y.buf.Reset()
+			if handleMethods(&y.methods, &y.buf, key, nil) {
+				y.writeString(y.buf.String())
+				y.w.Write(colonBytes)
+				return
+			}
+		}
+		y.scalar(key)
+		y.w.Write(colonBytes)
+		return
+	}
+
+	y.w.Write(yamlKeyBytes)
+	y.value(key, true)
+	y.indent()
+	y.w.Write(colonBytes)
+}
+
+// fyaml is a helper function to consolidate the logic from the various public
+// methods which take varying writers and config states.
+func fyaml(cs *ConfigState, w io.Writer, a ...interface{}) {
+	y := yamlStatePool.Get().(*yamlState)
+	defer yamlStatePut(y)
+	y.w = w
+	y.cs = cs
+	y.ci = cycleInfoGet()
+
+	// The results of the error and Stringer interfaces are written as the
+	// value, so they are always requested without the decoration
+	// ContinueOnMethod adds.
+	y.methods = *cs
+	y.methods.ContinueOnMethod = false
+
+	for _, arg := range a {
+		y.ci.resetPointers()
+		y.census = pointerCensus{counts: make(map[ptrKey]int)}
+		y.anchors = make(map[ptrKey]string)
+		y.depth = 0
+
+		v := reflect.ValueOf(arg)
+		y.census.collect(v)
+		w.Write(yamlDocumentBytes)
+		y.value(v, false)
+	}
+}
+
+var yamlStatePool = sync.Pool{New: func() interface{} {
+	return new(yamlState)
+}}
+
+func yamlStatePut(y *yamlState) {
+	cycleInfoPut(y.ci)
+	y.w = nil
+	y.ci = nil
+	y.cs = nil
+	y.methods = ConfigState{}
+	y.census = pointerCensus{}
+	y.anchors = nil
+	y.ignoreNextIndent = false
+	yamlStatePool.Put(y)
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ * Copyright (c) 2021 Anner van Hardenbroek
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/spewerspew/spew"
+)
+
+// yamlTests houses the tests to be performed against Fyaml.
+var yamlTests []renderTest
+
+// addYAMLTest is a helper method to append the passed config, input and
+// desired result to yamlTests.
+func addYAMLTest(cs *spew.ConfigState, in interface{}, want string) {
+	yamlTests = append(yamlTests, renderTest{cs, in, want})
+}
+
+func addScalarYAMLTests() {
+	cs := &spew.ConfigState{}
+	addYAMLTest(cs, nil, "--- null\n")
+	addYAMLTest(cs, true, "--- true\n")
+	addYAMLTest(cs, int8(-5), "--- -5\n")
+	addYAMLTest(cs, 1.5, "--- 1.5\n")
+	addYAMLTest(cs, math.NaN(), "--- .nan\n")
+	addYAMLTest(cs, math.Inf(-1), "--- -.inf\n")
+	addYAMLTest(cs, complex64(complex(1, -2)), "--- \"(1-2i)\"\n")
+	addYAMLTest(cs, "plain text", "--- plain text\n")
+	addYAMLTest(cs, "yes", "--- \"yes\"\n")
+	addYAMLTest(cs, "a: b\n", "--- \"a: b\\n\"\n")
+}
+
+func addContainerYAMLTests() {
+	type yamlStruct struct {
+		Name  string
+		f     float64
+		I     interface{}
+		Empty []int
+	}
+	cs := &spew.ConfigState{SortKeys: true}
+	addYAMLTest(cs, []int(nil), "--- null\n")
+	addYAMLTest(cs, []int{}, "--- []\n")
+	addYAMLTest(cs, [2]int{1, 2}, "---\n- 1\n- 2\n")
+	addYAMLTest(cs, [][]int{{1, 2}, {3}}, "---\n- - 1\n  - 2\n- - 3\n")
+	addYAMLTest(cs, []byte("hi"), "--- !!binary aGk=\n")
+	addYAMLTest(cs, map[string]int{"b": 2, "a": 1}, "---\na: 1\nb: 2\n")
+	addYAMLTest(cs, map[[2]int]int{{1, 2}: 3}, "---\n? - 1\n  - 2\n: 3\n")
+	addYAMLTest(cs, yamlStruct{"x", 1, nil, []int{}},
+		"---\nName: x\nf: 1\nI: null\nEmpty: []\n")
+	addYAMLTest(cs, []yamlStruct{{Name: "x"}},
+		"---\n- Name: x\n  f: 0\n  I: null\n  Empty: null\n")
+	addYAMLTest(cs, omitted{A: 1, Skip: 2}, "---\nA: 1\n")
+	addYAMLTest(cs, omitted{Opt: []int{3}}, "---\nA: 0\nOpt:\n  - 3\n")
+	addYAMLTest(cs, struct {
+		Skip int `spew:"-"`
+	}{1}, "--- {}\n")
+	addYAMLTest(&spew.ConfigState{MaxDepth: 1}, [][]int{{1}}, "---\n- []  # <max depth reached>\n")
+}
+
+func addPointerYAMLTests() {
+	cs := &spew.ConfigState{}
+	shared := &node{Name: "s"}
+	addYAMLTest(cs, (*node)(nil), "--- null\n")
+	addYAMLTest(cs, nodeCycle(), "--- &p1\nName: a\nNext:\n  Name: b\n  Next: *p1\n")
+	addYAMLTest(cs, []*node{shared, shared}, "---\n- &p1\n  Name: s\n  Next: null\n- *p1\n")
+}
+
+func addMethodYAMLTests() {
+	cs := &spew.ConfigState{}
+	addYAMLTest(cs, stringer("x"), "--- stringer x\n")
+	addYAMLTest(cs, customError(1), "--- \"error: 1\"\n")
+	addYAMLTest(cs, panicer(1), "--- 1  # (PANIC=test panic)\n")
+	addYAMLTest(&spew.ConfigState{YAMLTypeComments: true}, []interface{}{1, "x"},
+		"---  # []interface {}\n- 1  # int\n- x  # string\n")
+}
+
+func setupYAMLTests() {
+	if len(yamlTests) == 0 {
+		addScalarYAMLTests()
+		addContainerYAMLTests()
+		addPointerYAMLTests()
+		addMethodYAMLTests()
+	}
+}
+
+// TestYAML executes all of the tests described by yamlTests.
+func TestYAML(t *testing.T) {
+	setupYAMLTests()
+
+	t.Logf("Running %d tests", len(yamlTests))
+	for i, test := range yamlTests {
+		buf := new(bytes.Buffer)
+		test.cs.Fyaml(buf, test.in)
+		if s := buf.String(); s != test.want {
+			t.Errorf("YAML #%d\n got: %q want: %q", i, s, test.want)
+		}
+	}
+}
+
+// TestYAMLDocuments ensures every argument is written as a separate document.
+func TestYAMLDocuments(t *testing.T) {
+	s := spew.Syaml(1, "a", nil)
+	want := "--- 1\n--- a\n--- null\n"
+	if s != want {
+		t.Errorf("YAML documents\n got: %q want: %q", s, want)
+	}
+}